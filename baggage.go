@@ -0,0 +1,147 @@
+package spcontext
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// BaggageHeader is the W3C baggage header, carrying the propagated fields.
+const BaggageHeader = "baggage"
+
+// Limits of the propagated baggage, the minimums required by the W3C baggage specification.
+const (
+	maxBaggageMembers = 64
+	maxBaggageBytes   = 8192
+)
+
+// WithBaggage marks the fields with the given keys as propagating across service boundaries.
+// Inject writes them into the carrier as W3C baggage, and Extract and the server injectors restore
+// them into the fields of the new context. Fields with other keys are neither sent nor restored.
+func WithBaggage(keys ...string) ContextOption {
+	return func(ctx *Context) {
+		ctx.config.baggageKeys = append(ctx.config.baggageKeys[:len(ctx.config.baggageKeys):len(ctx.config.baggageKeys)], keys...)
+	}
+}
+
+// InjectBaggage writes the propagating fields into the carrier, merging them with the baggage
+// already there. Members which would exceed the size limits are skipped.
+func InjectBaggage(ctx *Context, carrier Carrier) {
+	if len(ctx.config.baggageKeys) == 0 {
+		return
+	}
+
+	members := parseBaggage(carrier.Get(BaggageHeader))
+	for _, key := range ctx.config.baggageKeys {
+		value := ctx.fields.Value(key)
+		if value == nil || !isBaggageKey(key) {
+			continue
+		}
+		if _, ok := value.(Valuer); ok {
+			continue
+		}
+
+		members = setBaggageMember(members, key, fmt.Sprint(value))
+	}
+
+	var header strings.Builder
+	for i, member := range members {
+		if i == maxBaggageMembers {
+			break
+		}
+
+		encoded := member.key + "=" + url.PathEscape(member.value)
+		if header.Len()+len(encoded)+1 > maxBaggageBytes {
+			continue
+		}
+		if header.Len() > 0 {
+			header.WriteByte(',')
+		}
+		header.WriteString(encoded)
+	}
+
+	if header.Len() > 0 {
+		carrier.Set(BaggageHeader, header.String())
+	}
+}
+
+// ExtractBaggage returns a context with the propagating fields read from the carrier.
+// Baggage exceeding the size limits is ignored.
+func ExtractBaggage(ctx *Context, carrier Carrier) *Context {
+	if len(ctx.config.baggageKeys) == 0 {
+		return ctx
+	}
+
+	header := carrier.Get(BaggageHeader)
+	if header == "" || len(header) > maxBaggageBytes {
+		return ctx
+	}
+
+	var kvs []interface{}
+	for _, member := range parseBaggage(header) {
+		for _, key := range ctx.config.baggageKeys {
+			if member.key == key {
+				kvs = append(kvs, key, member.value)
+				break
+			}
+		}
+	}
+
+	if len(kvs) == 0 {
+		return ctx
+	}
+	return ctx.With(kvs...)
+}
+
+type baggageMember struct {
+	key, value string
+}
+
+// parseBaggage parses the members of a W3C baggage header, skipping the invalid ones
+// and dropping their properties.
+func parseBaggage(header string) []baggageMember {
+	var out []baggageMember
+	for _, member := range strings.Split(header, ",") {
+		if len(out) == maxBaggageMembers {
+			break
+		}
+
+		member, _, _ = strings.Cut(member, ";")
+		key, value, ok := strings.Cut(member, "=")
+		if !ok {
+			continue
+		}
+
+		key = strings.TrimSpace(key)
+		value, err := url.PathUnescape(strings.TrimSpace(value))
+		if err != nil || !isBaggageKey(key) {
+			continue
+		}
+
+		out = setBaggageMember(out, key, value)
+	}
+	return out
+}
+
+func setBaggageMember(members []baggageMember, key, value string) []baggageMember {
+	for i := range members {
+		if members[i].key == key {
+			members[i].value = value
+			return members
+		}
+	}
+	return append(members, baggageMember{key: key, value: value})
+}
+
+// isBaggageKey checks whether the key is a valid W3C baggage key, which is an HTTP token.
+func isBaggageKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, r := range key {
+		if r <= ' ' || r >= 0x7f || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r) {
+			return false
+		}
+	}
+	return true
+}
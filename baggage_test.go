@@ -0,0 +1,70 @@
+package spcontext_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/spacelift-io/spcontext"
+)
+
+func TestBaggage(t *testing.T) {
+	newCtx := func() *spcontext.Context {
+		return spcontext.New(log.NewNopLogger(), spcontext.WithBaggage("account", "run_id", "request_id"))
+	}
+
+	t.Run("injects allowed fields", func(t *testing.T) {
+		ctx := newCtx().With("account", "acme corp", "run_id", "01ABC", "secret", "hunter2")
+		carrier := spcontext.MapCarrier{spcontext.BaggageHeader: "vendor=1;prop=x,run_id=old"}
+
+		spcontext.Inject(ctx, carrier)
+
+		assert.Equal(t, "vendor=1,run_id=01ABC,account=acme%20corp", carrier.Get("baggage"))
+	})
+
+	t.Run("skips members exceeding the size limit", func(t *testing.T) {
+		ctx := newCtx().With("account", strings.Repeat("a", 9000), "run_id", "01ABC")
+		header := http.Header{}
+
+		spcontext.Inject(ctx, spcontext.HeaderCarrier(header))
+
+		assert.Equal(t, "run_id=01ABC", header.Get("Baggage"))
+	})
+
+	t.Run("extracts allowed fields", func(t *testing.T) {
+		carrier := spcontext.MapCarrier{"Baggage": "account=acme%20corp, secret=hunter2, run_id=01ABC;ttl=5, invalid"}
+
+		ctx := spcontext.Extract(newCtx(), carrier)
+
+		assert.Equal(t, "acme corp", ctx.Fields().Value("account"))
+		assert.Equal(t, "01ABC", ctx.Fields().Value("run_id"))
+		assert.Nil(t, ctx.Fields().Value("secret"))
+	})
+
+	t.Run("ignores baggage without allow-list", func(t *testing.T) {
+		ctx := spcontext.New(log.NewNopLogger()).With("account", "acme")
+		carrier := spcontext.MapCarrier{}
+
+		spcontext.Inject(ctx, carrier)
+		extracted := spcontext.Extract(spcontext.New(log.NewNopLogger()), spcontext.MapCarrier{"baggage": "account=acme"})
+
+		assert.Empty(t, carrier)
+		assert.Nil(t, extracted.Fields().Value("account"))
+	})
+
+	t.Run("restored by the server injector", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.Header.Set("Baggage", "request_id=req-1")
+
+		var requestID interface{}
+		spcontext.ContextInjector(newCtx())(httptest.NewRecorder(), request, func(_ http.ResponseWriter, r *http.Request) {
+			requestID = spcontext.FromStdContext(r.Context()).Fields().Value("request_id")
+		})
+
+		assert.Equal(t, "req-1", requestID)
+	})
+}
@@ -1,11 +1,10 @@
 package spcontext
 
 import (
-	"runtime"
-	"strings"
+	"slices"
 
+	"github.com/bugsnag/bugsnag-go/v2"
 	bugsnagerrors "github.com/bugsnag/bugsnag-go/v2/errors"
-	"github.com/pkg/errors"
 )
 
 // BugsnagLogger wraps the given Context inside a bugsnag friendly logger.
@@ -18,14 +17,10 @@ func (l *BugsnagLogger) Printf(format string, v ...interface{}) {
 	l.Ctx.Infof(format, v...)
 }
 
-type stackTracer interface {
-	error
-	StackTrace() errors.StackTrace
-}
-
-// errorWithStackFrames satisfies bugsnag.ErrorWithStackFrames for a github.com/pkg/errors error.
+// errorWithStackFrames satisfies bugsnag.ErrorWithStackFrames for a reported error.
 type errorWithStackFrames struct {
-	err stackTracer
+	err    error
+	frames []StackFrame
 }
 
 // Cause returns initial error, provides compatibility for pkg/errors chains.
@@ -39,39 +34,89 @@ func (e *errorWithStackFrames) Error() string {
 }
 
 func (e *errorWithStackFrames) StackFrames() []bugsnagerrors.StackFrame {
-	stackTrace := e.err.StackTrace()
-
-	out := make([]bugsnagerrors.StackFrame, len(stackTrace))
-	for i, frame := range stackTrace {
-		pc := uintptr(frame) - 1
-		fn := runtime.FuncForPC(pc)
-		file, line := fn.FileLine(pc)
-
-		name := fn.Name()
-		var pkg string
-		var fnName string
-		if pkgDivider := strings.LastIndex(name, "/"); pkgDivider != -1 {
-			pkg = name[:pkgDivider]
-			fnName = name[pkgDivider+1:]
-			if fnDivider := strings.LastIndex(fnName, "."); fnDivider != -1 {
-				fnName = fnName[fnDivider+1:]
-			}
-		} else if pkgDivider := strings.Index(name, "."); pkgDivider != -1 {
-			pkg = name[:pkgDivider]
-			fnName = name[pkgDivider+1:]
-			if fnDivider := strings.LastIndex(fnName, "."); fnDivider != -1 {
-				fnName = fnName[fnDivider+1:]
-			}
+	out := make([]bugsnagerrors.StackFrame, len(e.frames))
+	for i, frame := range e.frames {
+		out[i] = bugsnagerrors.StackFrame{
+			File:           frame.File,
+			LineNumber:     frame.LineNumber,
+			Name:           frame.Function,
+			Package:        frame.Package,
+			ProgramCounter: frame.ProgramCounter,
 		}
+	}
+	return out
+}
 
-		out[i] = bugsnagerrors.StackFrame{
-			File:           file,
-			LineNumber:     line,
-			Name:           fnName,
-			Package:        pkg,
-			ProgramCounter: pc,
+// BugsnagRawData converts the report into the error and raw data arguments of a Bugsnag Notify call.
+func BugsnagRawData(report *Report) (error, []interface{}) {
+	metaData := bugsnag.MetaData{FieldsTab: report.UnsectionedFields()}
+	for tab, values := range report.Sections {
+		metaData[tab] = values
+	}
+
+	rawData := []interface{}{metaData}
+	if report.Ctx != nil {
+		rawData = append(rawData, report.Ctx)
+	}
+	rawData = append(rawData, bugsnag.ErrorClass{Name: report.ErrorClass})
+
+	if len(report.Tags) > 0 {
+		tags := make(map[string]interface{}, len(report.Tags))
+		for key, value := range report.Tags {
+			tags[key] = value
 		}
+		rawData = append(rawData, bugsnag.MetaData{"tags": tags})
 	}
 
-	return out
+	if report.User != nil {
+		rawData = append(rawData, bugsnag.User{Id: report.User.ID, Name: report.User.Name, Email: report.User.Email})
+	}
+
+	if report.Context != "" {
+		rawData = append(rawData, bugsnag.Context{String: report.Context})
+	}
+
+	// Bugsnag can't mark individual frames as in-project, so we pass the packages of such frames instead.
+	var projectPackages []string
+	for _, frame := range report.StackFrames {
+		if frame.InProject && !slices.Contains(projectPackages, frame.Package) {
+			projectPackages = append(projectPackages, frame.Package)
+		}
+	}
+
+	if report.AppVersion != "" || report.ReleaseStage != "" || len(projectPackages) > 0 {
+		rawData = append(rawData, bugsnag.Configuration{
+			AppVersion:      report.AppVersion,
+			ReleaseStage:    report.ReleaseStage,
+			ProjectPackages: projectPackages,
+		})
+	}
+
+	if report.Severity != SeverityDefault {
+		// Bugsnag severities are of an unexported type, so we can only rely on type inference here.
+		severity := bugsnag.SeverityError
+		switch report.Severity {
+		case SeverityWarning:
+			severity = bugsnag.SeverityWarning
+		case SeverityInfo:
+			severity = bugsnag.SeverityInfo
+		}
+
+		if report.Unhandled {
+			rawData = append(rawData, bugsnag.HandledState{
+				SeverityReason:   bugsnag.SeverityReasonHandledPanic,
+				OriginalSeverity: severity,
+				Unhandled:        true,
+			})
+		} else {
+			rawData = append(rawData, severity)
+		}
+	}
+
+	var err error = &errorWithStackFrames{err: report.Error, frames: report.StackFrames}
+	if len(report.StackFrames) == 0 {
+		err = report.Error
+	}
+
+	return err, rawData
 }
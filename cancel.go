@@ -0,0 +1,87 @@
+package spcontext
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+)
+
+// CancelCauseFunc is a function you can call to cancel the connected context with a cause.
+type CancelCauseFunc = context.CancelCauseFunc
+
+// WithCancelOrigins makes the cancelable contexts derived from the new context record the call site
+// of their cancellation. It's logged as the "cancel_origin" field when errors are reported or spans
+// are closed in a cancelled context, and returned by CancelOrigin.
+// The recorded cause wraps the original one, so it should be compared using errors.Is.
+func WithCancelOrigins() ContextOption {
+	return func(ctx *Context) {
+		ctx.config.recordCancelOrigins = true
+	}
+}
+
+// canceledError is the cause of a context cancellation, along with the call site which cancelled it.
+type canceledError struct {
+	cause  error
+	origin string
+}
+
+// Error returns the message of the cause.
+func (e *canceledError) Error() string {
+	return e.cause.Error()
+}
+
+// Unwrap returns the cause.
+func (e *canceledError) Unwrap() error {
+	return e.cause
+}
+
+// CancelOrigin returns the call site which cancelled the context, if it was recorded.
+func CancelOrigin(ctx context.Context) (string, bool) {
+	var canceled *canceledError
+	if errors.As(context.Cause(ctx), &canceled) {
+		return canceled.origin, true
+	}
+	return "", false
+}
+
+// cancelOriginParent returns the parent of a new cancelable context, and a function wrapping
+// the CancelFunc of the new context so that it records its call site, if enabled.
+func (ctx *Context) cancelOriginParent() (context.Context, func(context.CancelFunc) context.CancelFunc) {
+	if !ctx.config.recordCancelOrigins {
+		return ctx.Context, func(cancel context.CancelFunc) context.CancelFunc { return cancel }
+	}
+
+	parent, cancelCause := context.WithCancelCause(ctx.Context)
+	return parent, func(cancel context.CancelFunc) context.CancelFunc {
+		return func() {
+			cancelCause(&canceledError{cause: context.Canceled, origin: callerOrigin(1)})
+			cancel()
+		}
+	}
+}
+
+// callerOrigin describes the caller skip levels above the function calling it,
+// eg. "github.com/org/repo/pkg.Func (/src/pkg/file.go:42)".
+func callerOrigin(skip int) string {
+	pcs := make([]uintptr, 1)
+	if runtime.Callers(skip+2, pcs) == 0 {
+		return "unknown"
+	}
+
+	frame, _ := runtime.CallersFrames(pcs).Next()
+	return fmt.Sprintf("%s (%s:%d)", frame.Function, frame.File, frame.Line)
+}
+
+// cancellationFields returns the cause and origin of the context cancellation, if it was cancelled.
+func cancellationFields(ctx context.Context) []interface{} {
+	if ctx.Err() == nil {
+		return nil
+	}
+
+	out := []interface{}{"cancel_cause", context.Cause(ctx).Error()}
+	if origin, ok := CancelOrigin(ctx); ok {
+		out = append(out, "cancel_origin", origin)
+	}
+	return out
+}
@@ -0,0 +1,132 @@
+package spcontext_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/spacelift-io/spcontext"
+)
+
+func TestCancelOrigins(t *testing.T) {
+	t.Run("not recorded by default", func(t *testing.T) {
+		ctx, cancel := spcontext.WithCancel(spcontext.New(log.NewNopLogger()))
+		cancel()
+
+		_, ok := spcontext.CancelOrigin(ctx)
+		assert.False(t, ok)
+		assert.Equal(t, context.Canceled, context.Cause(ctx))
+	})
+
+	t.Run("records cancel call site", func(t *testing.T) {
+		ctx, cancel := spcontext.WithCancel(spcontext.New(log.NewNopLogger(), spcontext.WithCancelOrigins()))
+		cancel()
+
+		origin, ok := spcontext.CancelOrigin(ctx)
+		assert.True(t, ok)
+		assert.Contains(t, origin, "spcontext_test.TestCancelOrigins.func2 (")
+		assert.Contains(t, origin, "cancel_test.go:")
+		assert.ErrorIs(t, context.Cause(ctx), context.Canceled)
+		assert.Equal(t, context.Canceled, ctx.Err())
+	})
+
+	t.Run("keeps the cause of expired timeouts", func(t *testing.T) {
+		ctx, cancel := spcontext.WithTimeout(spcontext.New(log.NewNopLogger(), spcontext.WithCancelOrigins()), time.Nanosecond)
+		defer cancel()
+		<-ctx.Done()
+
+		_, ok := spcontext.CancelOrigin(ctx)
+		assert.False(t, ok)
+		assert.Equal(t, context.DeadlineExceeded, context.Cause(ctx))
+	})
+
+	t.Run("records custom causes", func(t *testing.T) {
+		errShutdown := errors.New("shutting down")
+		ctx, cancel := spcontext.WithCancelCause(spcontext.New(log.NewNopLogger(), spcontext.WithCancelOrigins()))
+		cancel(errShutdown)
+
+		origin, ok := spcontext.CancelOrigin(ctx)
+		assert.True(t, ok)
+		assert.Contains(t, origin, "cancel_test.go:")
+		assert.ErrorIs(t, context.Cause(ctx), errShutdown)
+	})
+
+	t.Run("logged with errors and spans", func(t *testing.T) {
+		logBuffer := bytes.NewBuffer(nil)
+		ctx, cancel := spcontext.WithCancelCause(spcontext.New(log.NewLogfmtLogger(logBuffer), spcontext.WithCancelOrigins()))
+		cancel(errors.New("client went away"))
+
+		_ = ctx.InternalError(ctx.Err(), "could not list stacks")
+
+		assert.Contains(t, logBuffer.String(), `cancel_cause="client went away" cancel_origin="github.com/spacelift-io/spcontext_test.TestCancelOrigins.func5 (`)
+	})
+}
+
+func TestContextPrimitives(t *testing.T) {
+	t.Run("without cancel", func(t *testing.T) {
+		base := spcontext.New(log.NewNopLogger()).With("fieldName", "fieldValue")
+		withCancel, cancel := spcontext.WithCancel(spcontext.WithValue(base, "keyName", "keyValue"))
+		withoutCancel := spcontext.WithoutCancel(withCancel)
+		cancel()
+
+		assert.NoError(t, withoutCancel.Err())
+		assert.NoError(t, context.Cause(withoutCancel))
+		assert.Equal(t, "keyValue", withoutCancel.Value("keyName"))
+		assert.Equal(t, "fieldValue", withoutCancel.Fields().Value("fieldName"))
+	})
+
+	t.Run("after func", func(t *testing.T) {
+		base := spcontext.New(log.NewNopLogger()).With("fieldName", "fieldValue")
+		ctx, cancel := spcontext.WithCancel(base)
+
+		called := make(chan *spcontext.Context)
+		spcontext.AfterFunc(ctx, func(ctx *spcontext.Context) {
+			called <- ctx
+		})
+		cancel()
+
+		cleanupCtx := <-called
+		assert.NoError(t, cleanupCtx.Err())
+		assert.Equal(t, "fieldValue", cleanupCtx.Fields().Value("fieldName"))
+	})
+
+	t.Run("after func stopped", func(t *testing.T) {
+		ctx, cancel := spcontext.WithCancel(spcontext.New(log.NewNopLogger()))
+		defer cancel()
+
+		stop := spcontext.AfterFunc(ctx, func(*spcontext.Context) {
+			t.Error("stopped function shouldn't be called")
+		})
+
+		assert.True(t, stop())
+	})
+
+	t.Run("injected contexts use the cause of the request", func(t *testing.T) {
+		appCtx, cancelApp := spcontext.WithCancelCause(spcontext.New(log.NewNopLogger()))
+		cancelApp(errors.New("shutting down"))
+		injector := spcontext.ContextInjector(appCtx)
+
+		requestCtx, cancelRequest := context.WithCancelCause(context.Background())
+		defer cancelRequest(nil)
+
+		var causes []error
+		handler := func(_ http.ResponseWriter, r *http.Request) {
+			causes = append(causes, context.Cause(r.Context()))
+		}
+
+		injector(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), handler)
+
+		errGone := errors.New("client went away")
+		cancelRequest(errGone)
+		injector(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(requestCtx), handler)
+
+		assert.Equal(t, []error{nil, errGone}, causes)
+	})
+}
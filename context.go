@@ -5,12 +5,15 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"runtime"
 	"strings"
 	"time"
 
 	"github.com/bugsnag/bugsnag-go/v2"
 	"github.com/go-kit/log"
 	pkgerrors "github.com/pkg/errors"
+
+	spinternal "github.com/spacelift-io/spcontext/internal"
 )
 
 // FieldsTab is the tab in bugsnag to put metadata fields into.
@@ -51,6 +54,26 @@ type Context struct {
 	Tracer Tracer
 
 	onSpanStartHooks []func(Span, Span)
+
+	config contextConfig
+}
+
+// contextConfig holds the configuration set on context creation, which is shared by all the derived contexts.
+type contextConfig struct {
+	// metadataTabs maps the names of report sections to the keys of the fields which belong to them.
+	metadataTabs map[string][]string
+	userFields   userFields
+	contextField string
+	appVersion   string
+	releaseStage string
+
+	projectPackages    []string
+	sourceContextLines int
+
+	recordCancelOrigins bool
+
+	// baggageKeys are the keys of the fields propagated across service boundaries.
+	baggageKeys []string
 }
 
 // ContextOption is used to optionally configure the context on creation.
@@ -113,6 +136,7 @@ func (ctx *Context) With(kvs ...interface{}) *Context {
 		Notifier:         ctx.Notifier,
 		Tracer:           ctx.Tracer,
 		onSpanStartHooks: ctx.onSpanStartHooks,
+		config:           ctx.config,
 	}
 }
 
@@ -140,6 +164,7 @@ func FromStdContext(stdCtx context.Context) *Context {
 			Notifier:         outCtx.Notifier,
 			Tracer:           outCtx.Tracer,
 			onSpanStartHooks: outCtx.onSpanStartHooks,
+			config:           outCtx.config,
 		}
 	}
 
@@ -163,6 +188,7 @@ func WithValue(ctx *Context, key, val interface{}) *Context {
 		Notifier:         ctx.Notifier,
 		Tracer:           ctx.Tracer,
 		onSpanStartHooks: ctx.onSpanStartHooks,
+		config:           ctx.config,
 	}
 }
 
@@ -171,7 +197,8 @@ type CancelFunc = context.CancelFunc
 
 // WithCancel returns a cancelable context. Use instead of context.WithCancel
 func WithCancel(ctx *Context) (*Context, CancelFunc) {
-	newCtx, cancel := context.WithCancel(ctx.Context)
+	parent, recordOrigin := ctx.cancelOriginParent()
+	newCtx, cancel := context.WithCancel(parent)
 	return &Context{
 		Context:          newCtx,
 		fields:           ctx.fields,
@@ -180,12 +207,40 @@ func WithCancel(ctx *Context) (*Context, CancelFunc) {
 		Notifier:         ctx.Notifier,
 		Tracer:           ctx.Tracer,
 		onSpanStartHooks: ctx.onSpanStartHooks,
+		config:           ctx.config,
+	}, recordOrigin(cancel)
+}
+
+// WithCancelCause returns a cancelable context, which records the cause of its cancellation.
+// Use instead of context.WithCancelCause.
+func WithCancelCause(ctx *Context) (*Context, CancelCauseFunc) {
+	newCtx, cancel := context.WithCancelCause(ctx.Context)
+	if ctx.config.recordCancelOrigins {
+		cancelCause := cancel
+		cancel = func(cause error) {
+			if cause == nil {
+				cause = context.Canceled
+			}
+			cancelCause(&canceledError{cause: cause, origin: callerOrigin(1)})
+		}
+	}
+
+	return &Context{
+		Context:          newCtx,
+		fields:           ctx.fields,
+		logger:           ctx.logger,
+		logLevel:         ctx.logLevel,
+		Notifier:         ctx.Notifier,
+		Tracer:           ctx.Tracer,
+		onSpanStartHooks: ctx.onSpanStartHooks,
+		config:           ctx.config,
 	}, cancel
 }
 
 // WithTimeout returns a context with a timeout. Use instead of context.WithTimeout.
 func WithTimeout(ctx *Context, timeout time.Duration) (*Context, context.CancelFunc) {
-	newCtx, cancel := context.WithTimeout(ctx.Context, timeout)
+	parent, recordOrigin := ctx.cancelOriginParent()
+	newCtx, cancel := context.WithTimeout(parent, timeout)
 	return &Context{
 		Context:          newCtx,
 		fields:           ctx.fields,
@@ -194,14 +249,16 @@ func WithTimeout(ctx *Context, timeout time.Duration) (*Context, context.CancelF
 		Notifier:         ctx.Notifier,
 		Tracer:           ctx.Tracer,
 		onSpanStartHooks: ctx.onSpanStartHooks,
-	}, cancel
+		config:           ctx.config,
+	}, recordOrigin(cancel)
 }
 
 // WithTimeoutCause returns a context with a timeout,
 // but also sets the cause of the returned Context when the timeout expires.
 // The returned [CancelFunc] does not set the cause. Use instead of context.WithTimeoutCause.
 func WithTimeoutCause(ctx *Context, timeout time.Duration, cause error) (*Context, context.CancelFunc) {
-	newCtx, cancel := context.WithTimeoutCause(ctx.Context, timeout, cause)
+	parent, recordOrigin := ctx.cancelOriginParent()
+	newCtx, cancel := context.WithTimeoutCause(parent, timeout, cause)
 	return &Context{
 		Context:          newCtx,
 		fields:           ctx.fields,
@@ -210,12 +267,14 @@ func WithTimeoutCause(ctx *Context, timeout time.Duration, cause error) (*Contex
 		Notifier:         ctx.Notifier,
 		Tracer:           ctx.Tracer,
 		onSpanStartHooks: ctx.onSpanStartHooks,
-	}, cancel
+		config:           ctx.config,
+	}, recordOrigin(cancel)
 }
 
 // WithDeadline returns a context with a deadline. Use instead of context.WithDeadline.
 func WithDeadline(ctx *Context, d time.Time) (*Context, context.CancelFunc) {
-	newCtx, cancel := context.WithDeadline(ctx.Context, d)
+	parent, recordOrigin := ctx.cancelOriginParent()
+	newCtx, cancel := context.WithDeadline(parent, d)
 	return &Context{
 		Context:          newCtx,
 		fields:           ctx.fields,
@@ -224,14 +283,16 @@ func WithDeadline(ctx *Context, d time.Time) (*Context, context.CancelFunc) {
 		Notifier:         ctx.Notifier,
 		Tracer:           ctx.Tracer,
 		onSpanStartHooks: ctx.onSpanStartHooks,
-	}, cancel
+		config:           ctx.config,
+	}, recordOrigin(cancel)
 }
 
 // WithDeadlineCause returns a context with a deadline,
 // but also sets the cause of the returned Context when the deadline is exceeded.
 // The returned [CancelFunc] does not set the cause. Use instead of context.WithDeadlineCause.
 func WithDeadlineCause(ctx *Context, d time.Time, cause error) (*Context, context.CancelFunc) {
-	newCtx, cancel := context.WithDeadlineCause(ctx.Context, d, cause)
+	parent, recordOrigin := ctx.cancelOriginParent()
+	newCtx, cancel := context.WithDeadlineCause(parent, d, cause)
 	return &Context{
 		Context:          newCtx,
 		fields:           ctx.fields,
@@ -240,7 +301,8 @@ func WithDeadlineCause(ctx *Context, d time.Time, cause error) (*Context, contex
 		Notifier:         ctx.Notifier,
 		Tracer:           ctx.Tracer,
 		onSpanStartHooks: ctx.onSpanStartHooks,
-	}, cancel
+		config:           ctx.config,
+	}, recordOrigin(cancel)
 }
 
 // BackgroundFrom creates a new context.Background() from the given Context.
@@ -255,33 +317,45 @@ func BackgroundFrom(ctx *Context) *Context {
 		Notifier:         ctx.Notifier,
 		Tracer:           ctx.Tracer,
 		onSpanStartHooks: ctx.onSpanStartHooks,
+		config:           ctx.config,
 	}
 }
 
 // BackgroundWithValuesFrom creates a new background context from the given Context.
 // This keeps all key-values, metadata fields and the logger/notifier configuration.
 // Use instead of BackgroundFrom when you want to keep key-value information.
+//
+// Deprecated: Use WithoutCancel, which it's equivalent to.
 func BackgroundWithValuesFrom(ctx *Context) *Context {
+	return WithoutCancel(ctx)
+}
+
+// WithoutCancel returns a context which is not cancelled when the given Context is.
+// This keeps all key-values, metadata fields and the logger/notifier configuration.
+// Use instead of context.WithoutCancel.
+func WithoutCancel(ctx *Context) *Context {
 	return &Context{
-		Context:          &backgroundWithValuesContext{ctx: ctx},
+		Context:          context.WithoutCancel(ctx.Context),
 		fields:           ctx.fields,
 		logger:           ctx.logger,
 		logLevel:         ctx.logLevel,
 		Notifier:         ctx.Notifier,
 		Tracer:           ctx.Tracer,
 		onSpanStartHooks: ctx.onSpanStartHooks,
+		config:           ctx.config,
 	}
 }
 
-type backgroundWithValuesContext struct {
-	ctx context.Context
+// AfterFunc arranges to call f in its own goroutine after the given Context is done.
+// f receives the Context without its cancellation, so that it can still be used for cleanup.
+// Calling the returned stop function stops the association of ctx with f, like for context.AfterFunc.
+// Use instead of context.AfterFunc.
+func AfterFunc(ctx *Context, f func(ctx *Context)) (stop func() bool) {
+	return context.AfterFunc(ctx.Context, func() {
+		f(WithoutCancel(ctx))
+	})
 }
 
-func (f *backgroundWithValuesContext) Deadline() (deadline time.Time, ok bool) { return }
-func (f *backgroundWithValuesContext) Done() <-chan struct{}                   { return nil }
-func (f *backgroundWithValuesContext) Err() error                              { return nil }
-func (f *backgroundWithValuesContext) Value(key interface{}) interface{}       { return f.ctx.Value(key) }
-
 func (ctx *Context) getEvaluatedFields() []interface{} {
 	return append(ctx.fields.EvaluateFields(), ctx.Tracer.GetLogFields(ctx)...)
 }
@@ -290,6 +364,25 @@ func (ctx *Context) shouldLog(level LogLevel) bool {
 	return level <= ctx.logLevel
 }
 
+// fieldsWithCaller returns the evaluated fields with the caller replaced by the given one,
+// for helpers logging on behalf of their callers.
+func (ctx *Context) fieldsWithCaller(caller string) []interface{} {
+	fields := ctx.getEvaluatedFields()
+	for i := 0; i < len(fields)/2; i++ {
+		if fields[2*i] == "caller" {
+			fields[2*i+1] = caller
+		}
+	}
+	return fields
+}
+
+// callerField returns the location of the caller the given number of frames up the stack,
+// formatted like the caller field.
+func callerField(skip int) string {
+	_, file, line, _ := runtime.Caller(skip + 1)
+	return fmt.Sprintf("%s:%d", file[strings.LastIndexByte(file, '/')+1:], line)
+}
+
 func (ctx *Context) log(fields []interface{}, level string, format string, args ...interface{}) {
 	fields = append(fields,
 		"level", level,
@@ -371,17 +464,69 @@ func (e notifiedError) Unwrap() error {
 	return e.internal
 }
 
+// IsReported returns true if the error, or any error it wraps, has already been
+// reported by a Context. Batches of an ErrorCollector which weren't reported yet only count
+// as reported if all their items were.
+func IsReported(err error) bool {
+	switch x := err.(type) {
+	case nil:
+		return false
+	case notifiedError:
+		return true
+	case *batchError:
+		return x.unreported == 0
+	case interface{ Unwrap() []error }:
+		for _, branch := range x.Unwrap() {
+			if IsReported(branch) {
+				return true
+			}
+		}
+		return false
+	case interface{ Unwrap() error }:
+		return IsReported(x.Unwrap())
+	default:
+		return false
+	}
+}
+
+// SafeMessageOf returns the user-safe message of a reported error. The second return
+// value is false if no reported error can be found in the error tree, in which case
+// the error should not be shown to users as-is.
+func SafeMessageOf(err error) (string, bool) {
+	var notifiedErr notifiedError
+	if !errors.As(err, &notifiedErr) {
+		return "", false
+	}
+	return notifiedErr.safe.Error(), true
+}
+
+// Internal returns the internal error of a reported error, which is the real underlying
+// cause meant for logs and observability tools. Errors which haven't been reported are
+// returned as-is.
+func Internal(err error) error {
+	var notifiedErr notifiedError
+	if !errors.As(err, &notifiedErr) {
+		return err
+	}
+	return notifiedErr.internal
+}
+
 func (ctx *Context) error(fields []interface{}, err error, internal InternalMessage, safe SafeMessage) error {
 	if err == nil {
 		return nil
 	}
 
 	internalErr := pkgerrors.Wrap(err, internal.Error())
-	if notifiedErr := (notifiedError{}); errors.As(err, &notifiedErr) {
+	if IsReported(err) {
 		// This error has already been notified to bugsnag before.
 		return notifiedError{internal: internalErr, safe: safe}
 	}
 
+	fields = append(fields, cancellationFields(ctx)...)
+
+	// Fields attached to the error take precedence over the ones from the context.
+	fields = spinternal.DeduplicateFieldList(append(fields, ErrorFields(err)...))
+
 	fieldsMap := make(map[string]interface{})
 	for i := 0; i < len(fields)/2; i++ {
 		fieldsMap[fields[2*i].(string)] = fields[2*i+1]
@@ -389,37 +534,32 @@ func (ctx *Context) error(fields []interface{}, err error, internal InternalMess
 
 	if ctx.Notifier != nil && !strings.Contains(err.Error(), context.Canceled.Error()) {
 		var parentErr = err
-		var st stackTracer
-		var errorClass string
-		curSt, ok := parentErr.(stackTracer)
-		if ok {
-			st = curSt
+		// The reported error is always the one passed in, so that notifiers can match its whole tree.
+		// Only the stack frames and the error class come from the error carrying the stack trace.
+		report := &Report{Error: parentErr, Fields: fieldsMap, Ctx: ctx}
+		if st := findStackTracer(parentErr); st != nil {
+			report.StackFrames = ctx.config.stackFrames(st)
+			report.ErrorClass = reflect.TypeOf(st).String()
+		} else {
+			// No error in the tree carries a stack trace, so we capture the one of the call site.
+			report.StackFrames = ctx.config.stackFrames(captureStack(parentErr))
+			report.ErrorClass = reflect.TypeOf(parentErr).String()
 		}
 
-		var curErr = err
-		for {
-			unwrapped := errors.Unwrap(curErr)
-			if unwrapped == nil {
-				break
-			}
-			curErr = unwrapped
-			curSt, ok := unwrapped.(stackTracer)
-			if !ok {
-				continue
+		// Multi-errors are reported once, with the messages of all their causes in the metadata.
+		if causes := spinternal.UnwrapErrors(parentErr); len(causes) > 1 {
+			messages := make([]string, min(len(causes), maxReportedCauses))
+			for i := range messages {
+				messages[i] = causes[i].Error()
 			}
-			st = curSt
-		}
-		if st != nil {
-			curErr = &errorWithStackFrames{err: st}
-			errorClass = reflect.TypeOf(st).String()
-		} else {
-			curErr = parentErr
-			errorClass = reflect.TypeOf(parentErr).String()
+			fieldsMap["causes"] = messages
 		}
 
 		fieldsMap["original_error"] = parentErr.Error()
 
-		if err := ctx.Notifier.Notify(curErr, bugsnag.MetaData{FieldsTab: fieldsMap}, ctx, bugsnag.ErrorClass{Name: errorClass}); err != nil {
+		ctx.config.applyTo(report)
+
+		if err := NotifyReport(ctx.Notifier, report); err != nil {
 			ctx.Errorf("error notifying the exception tracker: %v", err)
 		}
 	}
@@ -429,6 +569,9 @@ func (ctx *Context) error(fields []interface{}, err error, internal InternalMess
 	return notifiedError{internal: internalErr, safe: safe}
 }
 
+// maxReportedCauses limits the number of cause messages attached to a reported multi-error.
+const maxReportedCauses = 20
+
 // Fields returns the context fields.
 func (ctx *Context) Fields() *Fields {
 	return ctx.fields
@@ -3,9 +3,12 @@ package spcontext_test
 import (
 	"bytes"
 	"context"
+	stderrors "errors"
+	"fmt"
 	"testing"
 
 	"github.com/bugsnag/bugsnag-go/v2"
+	bugsnagerrors "github.com/bugsnag/bugsnag-go/v2/errors"
 	"github.com/franela/goblin"
 	"github.com/go-kit/log"
 	. "github.com/onsi/gomega"
@@ -439,3 +442,96 @@ func TestLogLevel(t *testing.T) {
 		assert.Contains(t, logBuffer.String(), `level=warning msg="warn message"`)
 	})
 }
+
+func TestReportedErrorInspection(t *testing.T) {
+	base := spcontext.New(log.NewNopLogger())
+	cause := errors.New("connection refused")
+	reported := base.Error(cause, errors.New("could not fetch stack"), errors.New("stack unavailable"))
+
+	testCases := []struct {
+		name string
+		err  error
+	}{
+		{name: "reported error", err: reported},
+		{name: "fmt.Errorf wrapped", err: fmt.Errorf("handler: %w", reported)},
+		{name: "pkg/errors wrapped", err: errors.Wrap(reported, "handler")},
+		{name: "joined", err: stderrors.Join(stderrors.New("other"), reported)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.True(t, spcontext.IsReported(tc.err))
+
+			safe, ok := spcontext.SafeMessageOf(tc.err)
+			assert.True(t, ok)
+			assert.Equal(t, "stack unavailable", safe)
+
+			internal := spcontext.Internal(tc.err)
+			assert.EqualError(t, internal, "could not fetch stack: connection refused")
+			assert.ErrorIs(t, internal, cause)
+		})
+	}
+
+	t.Run("unreported error", func(t *testing.T) {
+		err := errors.New("not reported")
+
+		assert.False(t, spcontext.IsReported(err))
+
+		_, ok := spcontext.SafeMessageOf(err)
+		assert.False(t, ok)
+
+		assert.Equal(t, err, spcontext.Internal(err))
+	})
+}
+
+func TestStackCaptureForErrorsWithoutStack(t *testing.T) {
+	base := spcontext.New(log.NewNopLogger())
+	notifier := new(testutils.MockNotifier)
+	base.Notifier = notifier
+
+	var notified error
+	notifier.On("Notify", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		notified = args.Error(0)
+	}).Return(nil)
+
+	_ = base.InternalError(stderrors.New("plain error"), "could not do it")
+
+	require.Error(t, notified)
+	assert.EqualError(t, notified, "plain error")
+
+	withFrames, ok := notified.(interface {
+		StackFrames() []bugsnagerrors.StackFrame
+	})
+	require.True(t, ok, "notified error should carry stack frames")
+
+	frames := withFrames.StackFrames()
+	require.NotEmpty(t, frames)
+	assert.Equal(t, "TestStackCaptureForErrorsWithoutStack", frames[0].Name)
+
+	extras := notifier.Calls[0].Arguments.Get(1).([]interface{})
+	assert.Contains(t, extras, bugsnag.ErrorClass{Name: "*errors.errorString"})
+}
+
+func TestJoinedErrorReporting(t *testing.T) {
+	base := spcontext.New(log.NewNopLogger())
+	notifier := new(testutils.MockNotifier)
+	base.Notifier = notifier
+
+	notifier.On("Notify", mock.Anything, mock.Anything).Return(nil)
+
+	withStack := errors.New("first failure")
+	joined := stderrors.Join(fmt.Errorf("wrapped: %w", withStack), stderrors.New("second failure"))
+
+	_ = base.InternalError(joined, "could not reconcile")
+
+	notifier.AssertNumberOfCalls(t, "Notify", 1)
+
+	notified := notifier.Calls[0].Arguments.Error(0)
+	assert.ErrorIs(t, notified, withStack)
+
+	extras := notifier.Calls[0].Arguments.Get(1).([]interface{})
+	assert.Contains(t, extras, bugsnag.ErrorClass{Name: "*errors.fundamental"})
+
+	metadata := extras[0].(bugsnag.MetaData)[spcontext.FieldsTab]
+	assert.Equal(t, []string{"first failure", "second failure"}, metadata["causes"])
+}
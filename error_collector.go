@@ -0,0 +1,164 @@
+package spcontext
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrorCollectorOption is used to optionally configure the ErrorCollector on creation.
+type ErrorCollectorOption func(c *ErrorCollector)
+
+// WithCollectedLogLevel sets the level at which each collected error is logged.
+// Only LogLevelDebug and LogLevelWarn are supported, defaults to LogLevelWarn.
+func WithCollectedLogLevel(level LogLevel) ErrorCollectorOption {
+	return func(c *ErrorCollector) {
+		c.logLevel = level
+	}
+}
+
+// WithSampleSize sets the maximum number of items listed in the aggregated report. Defaults to 10.
+func WithSampleSize(size int) ErrorCollectorOption {
+	return func(c *ErrorCollector) {
+		c.sampleSize = size
+	}
+}
+
+type collectedError struct {
+	err    error
+	fields []interface{}
+}
+
+// ErrorCollector gathers the errors of batch operations, eg. loops over many items, so that they
+// can be reported once instead of one by one. It's safe for concurrent use.
+type ErrorCollector struct {
+	ctx        *Context
+	logLevel   LogLevel
+	sampleSize int
+
+	mu     sync.Mutex
+	errors []collectedError
+}
+
+// NewErrorCollector creates a new ErrorCollector reporting using the context.
+func (ctx *Context) NewErrorCollector(opts ...ErrorCollectorOption) *ErrorCollector {
+	c := &ErrorCollector{
+		ctx:        ctx,
+		logLevel:   LogLevelWarn,
+		sampleSize: 10,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Add collects the error of a single item, described by the given alternating keys and values.
+// The error is logged right away, but only reported when Report is called. Nil errors are ignored.
+func (c *ErrorCollector) Add(err error, kvs ...interface{}) {
+	if err == nil {
+		return
+	}
+
+	// The item is logged on behalf of the caller, so that the caller field points at it.
+	itemCtx := c.ctx.With(kvs...)
+	caller := callerField(1)
+	if c.logLevel == LogLevelDebug {
+		if itemCtx.shouldLog(LogLevelDebug) {
+			itemCtx.log(itemCtx.fieldsWithCaller(caller), "debug", "batch item failed: %v", err)
+		}
+	} else if itemCtx.shouldLog(LogLevelWarn) {
+		itemCtx.log(itemCtx.fieldsWithCaller(caller), "warning", "batch item failed: %v", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.errors = append(c.errors, collectedError{err: err, fields: kvs})
+}
+
+// Len returns the number of collected errors.
+func (c *ErrorCollector) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.errors)
+}
+
+// Report sends a single notification for all the collected errors, listing their count and a sample
+// of the failed items, one per line. It returns nil if no errors were collected, and an error recognised
+// as already reported otherwise. Errors which were already reported on their own are not reported again.
+// The collected errors are cleared, so that the collector can be reused for the next batch.
+func (c *ErrorCollector) Report(message string) error {
+	c.mu.Lock()
+	collected := c.errors
+	c.errors = nil
+	c.mu.Unlock()
+
+	if len(collected) == 0 {
+		return nil
+	}
+
+	batchErr := &batchError{errs: make([]error, len(collected))}
+	sample := make([]string, 0, min(len(collected), c.sampleSize))
+	for i, item := range collected {
+		batchErr.errs[i] = item.err
+		if !IsReported(item.err) {
+			batchErr.unreported++
+		}
+
+		if len(sample) < c.sampleSize {
+			var description strings.Builder
+			for i := 0; i < len(item.fields)/2; i++ {
+				fmt.Fprintf(&description, "%v=%v ", item.fields[2*i], item.fields[2*i+1])
+			}
+			description.WriteString(item.err.Error())
+			sample = append(sample, description.String())
+		}
+	}
+
+	// If everything was already reported, this only wraps the errors without notifying again.
+	reportCtx := c.ctx.With(
+		"error_count", len(collected),
+		"error_sample", strings.Join(sample, "\n"),
+	)
+	return reportCtx.error(
+		reportCtx.fieldsWithCaller(callerField(1)),
+		batchErr,
+		InternalMessage(errors.New(message)),
+		SafeMessage(errors.New(internalErrorMessage)),
+	)
+}
+
+// batchError is the error of a batch operation, wrapping the errors of all the failed items.
+type batchError struct {
+	errs []error
+	// unreported is the number of items which weren't reported on their own.
+	unreported int
+}
+
+// Error returns a summary of the errors.
+func (e *batchError) Error() string {
+	if len(e.errs) == 1 {
+		return e.errs[0].Error()
+	}
+
+	messages := make([]string, 0, 3)
+	for _, err := range e.errs[:min(len(e.errs), 3)] {
+		messages = append(messages, err.Error())
+	}
+
+	out := fmt.Sprintf("%d errors occurred: %s", len(e.errs), strings.Join(messages, "; "))
+	if len(e.errs) > len(messages) {
+		out += "; ..."
+	}
+	return out
+}
+
+// Unwrap returns the errors of the failed items.
+func (e *batchError) Unwrap() []error {
+	return e.errs
+}
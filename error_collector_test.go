@@ -0,0 +1,146 @@
+package spcontext_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/bugsnag/bugsnag-go/v2"
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/spacelift-io/spcontext"
+	"github.com/spacelift-io/spcontext/testutils"
+)
+
+func TestErrorCollector(t *testing.T) {
+	errNotFound := errors.New("not found")
+
+	setup := func() (*spcontext.Context, *testutils.MockNotifier, *bytes.Buffer) {
+		logBuffer := bytes.NewBuffer(nil)
+		ctx := spcontext.New(log.NewLogfmtLogger(logBuffer), spcontext.WithLogLevel(spcontext.LogLevelDebug))
+		notifier := new(testutils.MockNotifier)
+		ctx.Notifier = notifier
+		return ctx, notifier, logBuffer
+	}
+
+	t.Run("nothing collected", func(t *testing.T) {
+		ctx, notifier, _ := setup()
+
+		collector := ctx.NewErrorCollector()
+		collector.Add(nil, "stack_id", "ignored")
+
+		assert.Zero(t, collector.Len())
+		assert.NoError(t, collector.Report("could not sync stacks"))
+		notifier.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything)
+	})
+
+	t.Run("reports once with a sample", func(t *testing.T) {
+		ctx, notifier, logBuffer := setup()
+		notifier.On("Notify", mock.Anything, mock.Anything).Return(nil).Once()
+
+		collector := ctx.NewErrorCollector(spcontext.WithSampleSize(2))
+		collector.Add(errNotFound, "stack_id", "a")
+		collector.Add(spcontext.WithFields(errors.New("timeout"), "attempt", 3), "stack_id", "b")
+		collector.Add(errors.New("forbidden"), "stack_id", "c")
+
+		err := collector.Report("could not sync stacks")
+
+		assert.True(t, spcontext.IsReported(err))
+		assert.ErrorIs(t, err, errNotFound)
+		assert.Contains(t, logBuffer.String(), `stack_id=a level=warning`)
+		assert.Contains(t, logBuffer.String(), `msg="batch item failed: timeout"`)
+
+		notifier.AssertExpectations(t)
+		fields := notifier.Calls[0].Arguments.Get(1).([]interface{})[0].(bugsnag.MetaData)[spcontext.FieldsTab]
+		assert.Equal(t, 3, fields["error_count"])
+		assert.Equal(t, "stack_id=a not found\nstack_id=b timeout", fields["error_sample"])
+		assert.Equal(t, []string{"not found", "timeout", "forbidden"}, fields["causes"])
+		assert.NotContains(t, fields, "attempt")
+		assert.Equal(t, "3 errors occurred: not found; timeout; forbidden", fields["original_error"])
+
+		// Reporting the batch again higher up the stack doesn't notify again.
+		_ = ctx.InternalError(err, "sync failed")
+		notifier.AssertNumberOfCalls(t, "Notify", 1)
+	})
+
+	t.Run("clears the reported errors", func(t *testing.T) {
+		ctx, notifier, _ := setup()
+		notifier.On("Notify", mock.Anything, mock.Anything).Return(nil)
+
+		collector := ctx.NewErrorCollector()
+		collector.Add(errors.New("timeout"), "stack_id", "a")
+		_ = collector.Report("could not sync stacks")
+
+		assert.Zero(t, collector.Len())
+		assert.NoError(t, collector.Report("could not sync stacks"))
+		notifier.AssertNumberOfCalls(t, "Notify", 1)
+
+		// The collector can be reused for the next batch.
+		collector.Add(errors.New("forbidden"), "stack_id", "b")
+		err := collector.Report("could not sync stacks")
+
+		assert.EqualError(t, spcontext.Internal(err), "could not sync stacks: forbidden")
+		notifier.AssertNumberOfCalls(t, "Notify", 2)
+	})
+
+	t.Run("logs at debug level", func(t *testing.T) {
+		ctx, _, logBuffer := setup()
+
+		collector := ctx.NewErrorCollector(spcontext.WithCollectedLogLevel(spcontext.LogLevelDebug))
+		collector.Add(errNotFound, "stack_id", "a")
+
+		assert.Contains(t, logBuffer.String(), `stack_id=a level=debug`)
+	})
+
+	t.Run("skips already reported errors", func(t *testing.T) {
+		ctx, notifier, _ := setup()
+		notifier.On("Notify", mock.Anything, mock.Anything).Return(nil)
+
+		collector := ctx.NewErrorCollector()
+		collector.Add(ctx.InternalError(errNotFound, "could not find stack"), "stack_id", "a")
+		notifier.AssertNumberOfCalls(t, "Notify", 1)
+
+		err := collector.Report("could not sync stacks")
+
+		assert.True(t, spcontext.IsReported(err))
+		notifier.AssertNumberOfCalls(t, "Notify", 1)
+	})
+
+	t.Run("wraps reported errors along with unreported ones", func(t *testing.T) {
+		ctx, notifier, _ := setup()
+		notifier.On("Notify", mock.Anything, mock.Anything).Return(nil)
+		errForbidden := errors.New("forbidden")
+
+		collector := ctx.NewErrorCollector()
+		collector.Add(ctx.InternalError(errNotFound, "could not find stack"), "stack_id", "a")
+		collector.Add(errForbidden, "stack_id", "b")
+
+		err := collector.Report("could not sync stacks")
+
+		notifier.AssertNumberOfCalls(t, "Notify", 2)
+		assert.ErrorIs(t, err, errNotFound)
+		assert.ErrorIs(t, err, errForbidden)
+	})
+
+	t.Run("logs the caller", func(t *testing.T) {
+		ctx, notifier, logBuffer := setup()
+		notifier.On("Notify", mock.Anything, mock.Anything).Return(nil)
+
+		collector := ctx.NewErrorCollector()
+		_, _, addLine, _ := runtime.Caller(0)
+		collector.Add(errNotFound, "stack_id", "a")
+		_, _, reportLine, _ := runtime.Caller(0)
+		_ = collector.Report("could not sync stacks")
+
+		assert.Contains(t, logBuffer.String(), fmt.Sprintf("caller=error_collector_test.go:%d ", addLine+1))
+		assert.Contains(t, logBuffer.String(), fmt.Sprintf("caller=error_collector_test.go:%d ", reportLine+1))
+		assert.NotContains(t, logBuffer.String(), "caller=error_collector.go")
+
+		fields := notifier.Calls[0].Arguments.Get(1).([]interface{})[0].(bugsnag.MetaData)[spcontext.FieldsTab]
+		assert.Equal(t, fmt.Sprintf("error_collector_test.go:%d", reportLine+1), fields["caller"])
+	})
+}
@@ -0,0 +1,77 @@
+package spcontext
+
+import (
+	"errors"
+)
+
+// fieldsError is an error carrying its own structured metadata fields.
+type fieldsError struct {
+	err    error
+	fields *Fields
+}
+
+// Error returns the message of the wrapped error.
+func (e *fieldsError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *fieldsError) Unwrap() error {
+	return e.err
+}
+
+// WithFields attaches the given alternating keys and values to the error.
+// The fields are merged into the metadata when the error is reported, logged or used to close a span.
+// Panics if odd number of arguments were passed or key(first value of each pair) is not a string.
+func WithFields(err error, kvs ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+	return &fieldsError{err: err, fields: (&Fields{}).With(kvs...)}
+}
+
+// WithErrorFields attaches the fields of the context to the error, so that they are not lost
+// when the error is reported higher up the stack using a different context.
+// Dynamic values, like the caller and timestamp, are skipped.
+func (ctx *Context) WithErrorFields(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var kvs []interface{}
+	fields := ctx.fields.makeFieldKVs()
+	for i := 0; i < len(fields)/2; i++ {
+		if _, ok := fields[2*i+1].(Valuer); ok {
+			continue
+		}
+		kvs = append(kvs, fields[2*i], fields[2*i+1])
+	}
+
+	return &fieldsError{err: err, fields: (&Fields{}).With(kvs...)}
+}
+
+// ErrorFields returns the fields attached to the error tree as alternating keys and values.
+// Fields attached closer to the root cause come first, so that the outer ones take precedence.
+func ErrorFields(err error) []interface{} {
+	if err == nil {
+		return nil
+	}
+
+	var out []interface{}
+	switch x := err.(type) {
+	case *batchError:
+		// Fields of the individual items are only listed in the sample of the batch.
+	case interface{ Unwrap() []error }:
+		for _, branch := range x.Unwrap() {
+			out = append(out, ErrorFields(branch)...)
+		}
+	default:
+		out = ErrorFields(errors.Unwrap(err))
+	}
+
+	if fieldsErr, ok := err.(*fieldsError); ok {
+		out = append(out, fieldsErr.fields.EvaluateFields()...)
+	}
+
+	return out
+}
@@ -0,0 +1,72 @@
+package spcontext_test
+
+import (
+	"bytes"
+	stderrors "errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bugsnag/bugsnag-go/v2"
+	"github.com/go-kit/log"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/spacelift-io/spcontext"
+	"github.com/spacelift-io/spcontext/testutils"
+)
+
+func TestErrorFields(t *testing.T) {
+	t.Run("collects fields through wrapping", func(t *testing.T) {
+		inner := spcontext.WithFields(errors.New("exit status 1"), "exit_code", 1, "path", "main.tf")
+		outer := spcontext.WithFields(fmt.Errorf("terraform apply: %w", inner), "path", "/workspace/main.tf")
+		joined := stderrors.Join(outer, spcontext.WithFields(errors.New("other"), "other", true))
+
+		assert.Equal(t, []interface{}{
+			"exit_code", 1,
+			"path", "main.tf",
+			"path", "/workspace/main.tf",
+			"other", true,
+		}, spcontext.ErrorFields(joined))
+	})
+
+	t.Run("captures context fields", func(t *testing.T) {
+		ctx := spcontext.New(log.NewNopLogger()).With("run_id", "01ABC")
+
+		err := ctx.WithErrorFields(errors.New("boom"))
+
+		assert.Equal(t, []interface{}{"run_id", "01ABC"}, spcontext.ErrorFields(err))
+	})
+
+	t.Run("nil error", func(t *testing.T) {
+		assert.NoError(t, spcontext.WithFields(nil, "key", "value"))
+		assert.Empty(t, spcontext.ErrorFields(nil))
+	})
+
+	t.Run("merged into reports and logs", func(t *testing.T) {
+		logBuffer := bytes.NewBuffer(nil)
+		ctx := spcontext.New(log.NewLogfmtLogger(logBuffer))
+		notifier := new(testutils.MockNotifier)
+		ctx.Notifier = notifier
+		notifier.On("Notify", mock.Anything, mock.Anything).Return(nil)
+
+		err := spcontext.WithFields(errors.New("exit status 1"), "exit_code", 1)
+		_ = ctx.InternalError(fmt.Errorf("terraform apply: %w", err), "could not apply")
+
+		extras := notifier.Calls[0].Arguments.Get(1).([]interface{})
+		assert.Equal(t, 1, extras[0].(bugsnag.MetaData)[spcontext.FieldsTab]["exit_code"])
+		assert.Contains(t, logBuffer.String(), "exit_code=1")
+	})
+
+	t.Run("logged once when repeating context fields", func(t *testing.T) {
+		logBuffer := bytes.NewBuffer(nil)
+		ctx := spcontext.New(log.NewLogfmtLogger(logBuffer)).With("path", "/workspace")
+
+		err := spcontext.WithFields(errors.New("file not found"), "path", "/workspace/main.tf")
+		_ = ctx.InternalError(err, "could not read the configuration")
+
+		assert.Equal(t, 1, strings.Count(logBuffer.String(), "path="))
+		assert.Contains(t, logBuffer.String(), "path=/workspace/main.tf")
+	})
+}
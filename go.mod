@@ -7,6 +7,7 @@ require (
 	github.com/aws/aws-xray-sdk-go v1.8.5
 	github.com/bugsnag/bugsnag-go/v2 v2.6.3
 	github.com/franela/goblin v0.0.0-20211003143422-0a4f594942bf
+	github.com/getsentry/sentry-go v0.45.1
 	github.com/go-kit/log v0.2.1
 	github.com/grpc-ecosystem/go-grpc-middleware v1.4.0
 	github.com/onsi/gomega v1.39.1
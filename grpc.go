@@ -0,0 +1,273 @@
+package spcontext
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCOption is used to optionally configure the gRPC interceptors.
+type GRPCOption func(cfg *grpcConfig)
+
+type grpcConfig struct {
+	tracing bool
+}
+
+// WithGRPCTracing makes the interceptors trace each call. They extract the propagated trace context,
+// start a span named after the method, and log the completion of the call with its status code.
+// Failed calls with server error codes, like Internal or Unavailable, close the span with an error,
+// but only the Internal, Unknown and DataLoss ones are reported, the other ones are only logged.
+// Panics close the span before being re-raised. Errors without a gRPC status are returned to the
+// caller converted using GRPCStatus.
+func WithGRPCTracing() GRPCOption {
+	return func(cfg *grpcConfig) {
+		cfg.tracing = true
+	}
+}
+
+func newGRPCConfig(opts []GRPCOption) grpcConfig {
+	var cfg grpcConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// GRPCUnaryContextInjector injects the given context into each unary call.
+// Swapping the underlying context.Context for the one in the request.
+func GRPCUnaryContextInjector(ctx *Context, opts ...GRPCOption) grpc.UnaryServerInterceptor {
+	cfg := newGRPCConfig(opts)
+
+	return func(stdCtx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		callCtx := cfg.serverContext(ctx, stdCtx)
+		if !cfg.tracing {
+			return handler(callCtx, req)
+		}
+
+		callCtx, finish := startGRPCServerSpan(callCtx, info.FullMethod)
+		defer finishOnPanic(finish)
+
+		resp, err := handler(callCtx, req)
+		return resp, finish(err)
+	}
+}
+
+// GRPCStreamContextInjector injects the given context into each stream.
+// Swapping the underlying context.Context for the one in the request.
+func GRPCStreamContextInjector(ctx *Context, opts ...GRPCOption) grpc.StreamServerInterceptor {
+	cfg := newGRPCConfig(opts)
+
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		callCtx := cfg.serverContext(ctx, stream.Context())
+		wrappedStream := grpc_middleware.WrapServerStream(stream)
+		if !cfg.tracing {
+			wrappedStream.WrappedContext = callCtx
+			return handler(srv, wrappedStream)
+		}
+
+		callCtx, finish := startGRPCServerSpan(callCtx, info.FullMethod)
+		defer finishOnPanic(finish)
+
+		wrappedStream.WrappedContext = callCtx
+		return finish(handler(srv, wrappedStream))
+	}
+}
+
+// serverContext injects the context into the one of the call, restoring the propagated fields,
+// and the trace context if tracing.
+func (cfg grpcConfig) serverContext(ctx *Context, stdCtx context.Context) *Context {
+	md, _ := metadata.FromIncomingContext(stdCtx)
+	if cfg.tracing {
+		return Extract(ctx.injectInto(stdCtx), MetadataCarrier(md))
+	}
+	return ExtractBaggage(ctx.injectInto(stdCtx), MetadataCarrier(md))
+}
+
+// startGRPCServerSpan starts the span of a call. The returned function finishes it with the error
+// returned by the handler, returning the error which should be sent to the caller.
+func startGRPCServerSpan(ctx *Context, method string) (*Context, func(err error) error) {
+	start := time.Now()
+
+	ctx = ctx.With("grpc.method", method)
+	spanCtx, span := ctx.StartSpan(
+		WithOperation("grpc.server"),
+		WithResource("%s", method),
+		WithSpanKind(SpanKindServer),
+		WithTags("grpc.method", method),
+	)
+
+	return spanCtx, func(err error) error {
+		code, out := codes.OK, err
+		if err != nil {
+			if s, ok := status.FromError(err); ok {
+				code = s.Code()
+			} else {
+				code = CodeOf(err)
+				out = GRPCStatus(err).Err()
+			}
+		}
+
+		fields := []interface{}{"grpc.code", code.String(), "grpc.latency", time.Since(start)}
+		span.SetTags(fields...)
+		logCtx := spanCtx.With(fields...)
+
+		switch {
+		case code == codes.OK:
+			logCtx.Infof("gRPC call finished")
+			span.Close(nil)
+		case code == codes.Internal || code == codes.Unknown || code == codes.DataLoss:
+			if IsReported(err) {
+				// Errors reported by the handler aren't reported again, but the call is still logged.
+				logCtx.Errorf("gRPC call failed: %v", Internal(err))
+			} else {
+				// Reporting the error logs the call along with it.
+				_ = logCtx.InternalError(err, "gRPC call failed")
+			}
+			span.Close(err)
+		case HTTPStatusFromCode(code) >= http.StatusInternalServerError:
+			// Other server errors, like timeouts or unavailable dependencies, are usually transient.
+			logCtx.Warnf("gRPC call failed: %v", err)
+			span.Close(err)
+		default:
+			// Client errors are expected, so they're neither reported nor marked on the span.
+			logCtx.Warnf("gRPC call failed: %v", err)
+			span.Close(nil)
+		}
+
+		return out
+	}
+}
+
+// finishOnPanic must be deferred directly, so that it can recover panics of the handler.
+// It finishes the span of the call with the recovered panic, which is then re-raised.
+func finishOnPanic(finish func(err error) error) {
+	if recovered := recover(); recovered != nil {
+		_ = finish(fmt.Errorf("panic: %v", recovered))
+		panic(recovered)
+	}
+}
+
+// GRPCUnaryClientInterceptor traces outgoing unary calls made with a *Context.
+// It starts a client span for each call, injects the trace context and the propagating fields
+// into the outgoing metadata, and logs failed calls.
+func GRPCUnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(stdCtx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		var p peer.Peer
+		callCtx, finish := startGRPCClientSpan(FromStdContext(stdCtx), method, cc.Target(), &p)
+
+		err := invoker(callCtx, method, req, reply, cc, append(opts, grpc.Peer(&p))...)
+		finish(err)
+		return err
+	}
+}
+
+// GRPCStreamClientInterceptor traces outgoing streams made with a *Context, like GRPCUnaryClientInterceptor.
+// The span is closed when the stream ends, or when its context is done, so the responses should be received
+// until an error is returned, or the context canceled.
+func GRPCStreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(stdCtx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		var p peer.Peer
+		callCtx, finish := startGRPCClientSpan(FromStdContext(stdCtx), method, cc.Target(), &p)
+
+		stream, err := streamer(callCtx, desc, cc, method, opts...)
+		if err != nil {
+			finish(err)
+			return nil, err
+		}
+
+		// The peer is read from the stream rather than filled in when it ends, as the span may be finished
+		// concurrently when the context of the stream is done.
+		if streamPeer, ok := peer.FromContext(stream.Context()); ok {
+			p = *streamPeer
+		}
+
+		traced := &tracedClientStream{ClientStream: stream, serverStreams: desc.ServerStreams, finish: finish}
+		context.AfterFunc(stream.Context(), traced.finishCanceled)
+		return traced, nil
+	}
+}
+
+// tracedClientStream finishes the span of a stream when it ends, or when its context is done
+// if it's abandoned before being received until the end.
+type tracedClientStream struct {
+	grpc.ClientStream
+	serverStreams bool
+	finish        func(err error)
+	once          sync.Once
+	receiving     atomic.Int32
+}
+
+func (s *tracedClientStream) RecvMsg(m interface{}) error {
+	s.receiving.Add(1)
+	defer s.receiving.Add(-1)
+
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case errors.Is(err, io.EOF):
+		s.once.Do(func() { s.finish(nil) })
+	case err != nil:
+		s.once.Do(func() { s.finish(err) })
+	case !s.serverStreams:
+		// Streams without server streaming end with the single response.
+		s.once.Do(func() { s.finish(nil) })
+	}
+	return err
+}
+
+// finishCanceled finishes the span when the context of the stream is done. The context is also done
+// when the stream ends while receiving, in which case the span is finished with the received error.
+func (s *tracedClientStream) finishCanceled() {
+	if s.receiving.Load() > 0 {
+		return
+	}
+	s.once.Do(func() { s.finish(status.FromContextError(s.Context().Err()).Err()) })
+}
+
+// startGRPCClientSpan starts the span of an outgoing call, returning the context with the outgoing metadata.
+// The returned function finishes it with the error of the call, using the peer filled in by the call.
+func startGRPCClientSpan(ctx *Context, method, target string, p *peer.Peer) (context.Context, func(err error)) {
+	start := time.Now()
+
+	fields := []interface{}{"grpc.method", method, "grpc.target", target}
+	spanCtx, span := ctx.StartSpan(
+		WithOperation("grpc.client"),
+		WithResource("%s", method),
+		WithSpanKind(SpanKindClient),
+		WithTags(fields...),
+	)
+
+	md, _ := metadata.FromOutgoingContext(spanCtx)
+	md = md.Copy()
+	Inject(spanCtx, MetadataCarrier(md))
+
+	return metadata.NewOutgoingContext(spanCtx, md), func(err error) {
+		code := status.Code(err)
+		fields := append(fields, "grpc.code", code.String(), "grpc.latency", time.Since(start))
+		if p.Addr != nil {
+			fields = append(fields, "grpc.peer", p.Addr.String())
+		}
+		span.SetTags(fields...)
+
+		if err != nil {
+			spanCtx.With(fields...).Warnf("gRPC call failed: %v", err)
+		}
+
+		// Like on the server side, client errors are expected, so they aren't marked on the span.
+		if HTTPStatusFromCode(code) < http.StatusInternalServerError {
+			err = nil
+		}
+		span.Close(err)
+	}
+}
@@ -0,0 +1,318 @@
+package spcontext_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/spacelift-io/spcontext"
+	"github.com/spacelift-io/spcontext/testutils"
+)
+
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestGRPCServerInterceptors(t *testing.T) {
+	const method = "/spacelift.Stacks/GetStack"
+
+	setup := func() (*spcontext.Context, *propagatingTracer, *testutils.MockNotifier, *bytes.Buffer) {
+		tracer := &propagatingTracer{}
+		notifier := new(testutils.MockNotifier)
+		logBuffer := bytes.NewBuffer(nil)
+		ctx := spcontext.New(
+			log.NewLogfmtLogger(logBuffer),
+			spcontext.WithTracer(tracer),
+			spcontext.WithNotifier(notifier),
+			spcontext.WithBaggage("request_id"),
+		)
+		return ctx, tracer, notifier, logBuffer
+	}
+
+	incoming := metadata.NewIncomingContext(context.Background(), metadata.Pairs("test-parent", "remote", "baggage", "request_id=req-1"))
+	unaryInfo := &grpc.UnaryServerInfo{FullMethod: method}
+
+	t.Run("injects context without tracing", func(t *testing.T) {
+		ctx, tracer, _, _ := setup()
+
+		_, err := spcontext.GRPCUnaryContextInjector(ctx)(incoming, nil, unaryInfo, func(callCtx context.Context, _ interface{}) (interface{}, error) {
+			assert.Equal(t, "req-1", spcontext.FromStdContext(callCtx).Fields().Value("request_id"))
+			assert.Nil(t, callCtx.Value(remoteParentKey{}))
+			return nil, nil
+		})
+
+		assert.NoError(t, err)
+		assert.Empty(t, tracer.started)
+	})
+
+	t.Run("traces successful calls", func(t *testing.T) {
+		ctx, tracer, _, logBuffer := setup()
+
+		resp, err := spcontext.GRPCUnaryContextInjector(ctx, spcontext.WithGRPCTracing())(incoming, nil, unaryInfo, func(callCtx context.Context, _ interface{}) (interface{}, error) {
+			assert.Equal(t, "remote", callCtx.Value(remoteParentKey{}))
+			return "stack", nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "stack", resp)
+		require.Len(t, tracer.started, 1)
+		assert.Equal(t, method, tracer.started[0].Resource)
+		assert.Equal(t, spcontext.SpanKindServer, tracer.started[0].Kind)
+		assert.Equal(t, "OK", fieldMap(tracer.fields[0])["grpc.code"])
+		assert.Contains(t, logBuffer.String(), `grpc.method=/spacelift.Stacks/GetStack`)
+		assert.Contains(t, logBuffer.String(), `grpc.code=OK`)
+		assert.Contains(t, logBuffer.String(), `level=info msg="gRPC call finished"`)
+	})
+
+	t.Run("logs client errors", func(t *testing.T) {
+		ctx, tracer, notifier, logBuffer := setup()
+
+		_, err := spcontext.GRPCUnaryContextInjector(ctx, spcontext.WithGRPCTracing())(incoming, nil, unaryInfo, func(context.Context, interface{}) (interface{}, error) {
+			return nil, status.Error(codes.NotFound, "stack not found")
+		})
+
+		assert.Equal(t, codes.NotFound, status.Code(err))
+		assert.NoError(t, tracer.errs[0])
+		assert.Contains(t, logBuffer.String(), `grpc.code=NotFound`)
+		assert.Contains(t, logBuffer.String(), `level=warning`)
+		notifier.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything)
+	})
+
+	t.Run("reports server errors", func(t *testing.T) {
+		ctx, tracer, notifier, logBuffer := setup()
+		notifier.On("Notify", mock.Anything, mock.Anything).Return(nil).Once()
+
+		stream := &fakeServerStream{ctx: incoming}
+		err := spcontext.GRPCStreamContextInjector(ctx, spcontext.WithGRPCTracing())(nil, stream, &grpc.StreamServerInfo{FullMethod: method}, func(_ interface{}, stream grpc.ServerStream) error {
+			assert.Equal(t, "req-1", spcontext.FromStdContext(stream.Context()).Fields().Value("request_id"))
+			return errors.New("database is down")
+		})
+
+		assert.Equal(t, status.New(codes.Internal, "internal error").Err(), err)
+		assert.EqualError(t, tracer.errs[0], "database is down")
+		assert.Contains(t, logBuffer.String(), `grpc.code=Internal`)
+		assert.Contains(t, logBuffer.String(), `level=error msg="gRPC call failed: database is down"`)
+		notifier.AssertExpectations(t)
+	})
+
+	t.Run("logs errors reported by the handler", func(t *testing.T) {
+		ctx, tracer, notifier, logBuffer := setup()
+		notifier.On("Notify", mock.Anything, mock.Anything).Return(nil).Once()
+
+		_, err := spcontext.GRPCUnaryContextInjector(ctx, spcontext.WithGRPCTracing())(incoming, nil, unaryInfo, func(callCtx context.Context, _ interface{}) (interface{}, error) {
+			return nil, spcontext.FromStdContext(callCtx).InternalError(errors.New("db down"), "could not load")
+		})
+
+		assert.Equal(t, codes.Internal, status.Code(err))
+		assert.Error(t, tracer.errs[0])
+		assert.Contains(t, logBuffer.String(), `msg="could not load: db down"`)
+		assert.Contains(t, logBuffer.String(), `grpc.code=Internal`)
+		assert.Contains(t, logBuffer.String(), `grpc.latency=`)
+		assert.Contains(t, logBuffer.String(), `level=error msg="gRPC call failed: could not load: db down"`)
+		notifier.AssertNumberOfCalls(t, "Notify", 1)
+	})
+
+	t.Run("doesn't report timeouts", func(t *testing.T) {
+		ctx, tracer, notifier, logBuffer := setup()
+
+		_, err := spcontext.GRPCUnaryContextInjector(ctx, spcontext.WithGRPCTracing())(incoming, nil, unaryInfo, func(context.Context, interface{}) (interface{}, error) {
+			return nil, context.DeadlineExceeded
+		})
+
+		assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+		assert.Equal(t, context.DeadlineExceeded, tracer.errs[0])
+		assert.Contains(t, logBuffer.String(), `grpc.code=DeadlineExceeded`)
+		assert.Contains(t, logBuffer.String(), `level=warning`)
+		notifier.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything)
+	})
+
+	t.Run("closes the span on panic", func(t *testing.T) {
+		ctx, tracer, notifier, _ := setup()
+		notifier.On("Notify", mock.Anything, mock.Anything).Return(nil).Once()
+
+		assert.PanicsWithValue(t, "boom", func() {
+			_, _ = spcontext.GRPCUnaryContextInjector(ctx, spcontext.WithGRPCTracing())(incoming, nil, unaryInfo, func(context.Context, interface{}) (interface{}, error) {
+				panic("boom")
+			})
+		})
+
+		require.Len(t, tracer.closed, 1)
+		assert.EqualError(t, tracer.errs[0], "panic: boom")
+		assert.Equal(t, "Internal", fieldMap(tracer.fields[0])["grpc.code"])
+		notifier.AssertExpectations(t)
+	})
+}
+
+// notifyingTracer signals the closed spans, so that the ones closed in the background can be awaited.
+type notifyingTracer struct {
+	propagatingTracer
+	closedSpans chan struct{}
+}
+
+func (n *notifyingTracer) OnSpanCloseWithConfig(ctx *spcontext.Context, err error, fields []interface{}, cfg spcontext.SpanCloseConfig) {
+	n.propagatingTracer.OnSpanCloseWithConfig(ctx, err, fields, cfg)
+	n.closedSpans <- struct{}{}
+}
+
+func (n *notifyingTracer) awaitClosed(t *testing.T) {
+	select {
+	case <-n.closedSpans:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the span wasn't closed")
+	}
+}
+
+func TestGRPCClientInterceptors(t *testing.T) {
+	setup := func(t *testing.T) (*spcontext.Context, *notifyingTracer, *bytes.Buffer, healthpb.HealthClient, *context.Context) {
+		serverCtx := spcontext.New(log.NewNopLogger(), spcontext.WithTracer(&propagatingTracer{}), spcontext.WithBaggage("request_id"))
+		var received context.Context
+
+		healthServer := health.NewServer()
+		healthServer.SetServingStatus("stacks", healthpb.HealthCheckResponse_SERVING)
+
+		listener := bufconn.Listen(1 << 20)
+		server := grpc.NewServer(
+			grpc.ChainUnaryInterceptor(
+				spcontext.GRPCUnaryContextInjector(serverCtx, spcontext.WithGRPCTracing()),
+				func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+					received = ctx
+					return handler(ctx, req)
+				},
+			),
+			grpc.StreamInterceptor(spcontext.GRPCStreamContextInjector(serverCtx, spcontext.WithGRPCTracing())),
+		)
+		healthpb.RegisterHealthServer(server, healthServer)
+		go func() { _ = server.Serve(listener) }()
+		t.Cleanup(server.Stop)
+
+		conn, err := grpc.NewClient(
+			"passthrough:///bufnet",
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithUnaryInterceptor(spcontext.GRPCUnaryClientInterceptor()),
+			grpc.WithStreamInterceptor(spcontext.GRPCStreamClientInterceptor()),
+		)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = conn.Close() })
+
+		clientTracer := &notifyingTracer{closedSpans: make(chan struct{}, 1)}
+		logBuffer := bytes.NewBuffer(nil)
+		ctx := spcontext.New(
+			log.NewLogfmtLogger(logBuffer),
+			spcontext.WithTracer(clientTracer),
+			spcontext.WithBaggage("request_id"),
+		).With("request_id", "req-1")
+
+		return ctx, clientTracer, logBuffer, healthpb.NewHealthClient(conn), &received
+	}
+
+	t.Run("traces successful unary calls", func(t *testing.T) {
+		ctx, clientTracer, logBuffer, client, received := setup(t)
+
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "stacks"})
+
+		require.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+
+		require.Len(t, clientTracer.started, 1)
+		assert.Equal(t, "grpc.client", clientTracer.started[0].Operation)
+		assert.Equal(t, "/grpc.health.v1.Health/Check", clientTracer.started[0].Resource)
+		assert.Equal(t, spcontext.SpanKindClient, clientTracer.started[0].Kind)
+		assert.NoError(t, clientTracer.errs[0])
+		assert.Equal(t, "OK", fieldMap(clientTracer.fields[0])["grpc.code"])
+		assert.Equal(t, "bufconn", fieldMap(clientTracer.fields[0])["grpc.peer"])
+		assert.Empty(t, logBuffer.String())
+
+		require.NotNil(t, *received)
+		assert.Equal(t, "parent", (*received).Value(remoteParentKey{}))
+		assert.Equal(t, "req-1", spcontext.FromStdContext(*received).Fields().Value("request_id"))
+	})
+
+	t.Run("logs failed unary calls", func(t *testing.T) {
+		ctx, clientTracer, logBuffer, client, _ := setup(t)
+
+		_, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "unknown"})
+
+		assert.Equal(t, codes.NotFound, status.Code(err))
+		require.Len(t, clientTracer.errs, 1)
+		assert.NoError(t, clientTracer.errs[0], "client errors shouldn't be marked on the span")
+		assert.Equal(t, "NotFound", fieldMap(clientTracer.fields[0])["grpc.code"])
+		assert.Contains(t, logBuffer.String(), `level=warning`)
+		assert.Contains(t, logBuffer.String(), `grpc.method=/grpc.health.v1.Health/Check`)
+		assert.Contains(t, logBuffer.String(), `grpc.code=NotFound`)
+		assert.Contains(t, logBuffer.String(), `grpc.peer=bufconn`)
+	})
+
+	t.Run("traces streams until they end", func(t *testing.T) {
+		ctx, clientTracer, _, client, _ := setup(t)
+		streamCtx, cancel := spcontext.WithCancel(ctx)
+
+		stream, err := client.Watch(streamCtx, &healthpb.HealthCheckRequest{Service: "stacks"})
+		require.NoError(t, err)
+
+		resp, err := stream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+		assert.Empty(t, clientTracer.closed)
+
+		cancel()
+		_, err = stream.Recv()
+		clientTracer.awaitClosed(t)
+
+		assert.Equal(t, codes.Canceled, status.Code(err))
+		require.Len(t, clientTracer.started, 1)
+		assert.Equal(t, "/grpc.health.v1.Health/Watch", clientTracer.started[0].Resource)
+		require.Len(t, clientTracer.closed, 1)
+		assert.Equal(t, "Canceled", fieldMap(clientTracer.fields[0])["grpc.code"])
+	})
+
+	t.Run("traces abandoned streams until they're canceled", func(t *testing.T) {
+		ctx, clientTracer, _, client, _ := setup(t)
+		streamCtx, cancel := spcontext.WithCancel(ctx)
+
+		_, err := client.Watch(streamCtx, &healthpb.HealthCheckRequest{Service: "stacks"})
+		require.NoError(t, err)
+		assert.Empty(t, clientTracer.closed)
+
+		cancel()
+		clientTracer.awaitClosed(t)
+
+		require.Len(t, clientTracer.closed, 1)
+		assert.NoError(t, clientTracer.errs[0])
+		assert.Equal(t, "Canceled", fieldMap(clientTracer.fields[0])["grpc.code"])
+		assert.Equal(t, "bufconn", fieldMap(clientTracer.fields[0])["grpc.peer"])
+	})
+
+	t.Run("marks server errors on the span", func(t *testing.T) {
+		ctx, clientTracer, _, client, _ := setup(t)
+		deadlineCtx, cancel := spcontext.WithTimeout(ctx, -time.Second)
+		defer cancel()
+
+		_, err := client.Check(deadlineCtx, &healthpb.HealthCheckRequest{Service: "stacks"})
+
+		assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+		require.Len(t, clientTracer.errs, 1)
+		assert.Equal(t, codes.DeadlineExceeded, status.Code(clientTracer.errs[0]))
+	})
+}
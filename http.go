@@ -0,0 +1,292 @@
+package spcontext
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HTTPOption is used to optionally configure the HTTP middleware.
+type HTTPOption func(cfg *httpConfig)
+
+type httpConfig struct {
+	route func(r *http.Request) string
+}
+
+// WithRouteFunc sets the function returning the route of a request, eg. "/stacks/{id}", used to name
+// server spans. Defaults to the pattern matched by http.ServeMux, if the middleware wraps its handlers.
+// Otherwise, the pattern matched by a wrapped http.ServeMux is only known after the request is served,
+// so the span is renamed when it's closed.
+func WithRouteFunc(route func(r *http.Request) string) HTTPOption {
+	return func(cfg *httpConfig) {
+		cfg.route = route
+	}
+}
+
+// HTTPMiddleware injects the given context into each request, like ContextInjector, and traces it.
+// It extracts the propagated trace context, starts a server span named after the route, and logs
+// a single access line per request. Responses with a 5xx status close the span with an error.
+func HTTPMiddleware(ctx *Context, opts ...HTTPOption) func(http.Handler) http.Handler {
+	cfg := httpConfig{
+		route: func(r *http.Request) string { return r.Pattern },
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			route := cfg.route(r)
+			resource := routeResource(r.Method, route)
+
+			reqCtx := Extract(ctx.injectInto(r.Context()), HeaderCarrier(r.Header)).With("http.method", r.Method)
+			if route != "" {
+				reqCtx = reqCtx.With("http.route", route)
+			}
+
+			spanCtx, span := reqCtx.StartSpan(
+				WithOperation("http.request"),
+				WithResource("%s", resource),
+				WithSpanKind(SpanKindServer),
+				WithTags("http.method", r.Method, "http.url", r.URL.Path),
+			)
+
+			recorder := &responseRecorder{ResponseWriter: w}
+			served := r.WithContext(spanCtx)
+
+			defer func() {
+				recovered := recover()
+				if recovered != nil && recorder.status == 0 {
+					recorder.status = http.StatusInternalServerError
+				}
+
+				fields := []interface{}{
+					"http.status_code", recorder.statusCode(),
+					"http.request_bytes", r.ContentLength,
+					"http.response_bytes", recorder.bytes,
+					"http.latency", time.Since(start),
+				}
+				var closeOpts []SpanCloseOption
+				if route == "" && served.Pattern != "" {
+					fields = append(fields, "http.route", served.Pattern)
+					closeOpts = append(closeOpts, WithCloseResource("%s", routeResource(r.Method, served.Pattern)))
+				}
+				span.SetTags(fields...)
+
+				logCtx := spanCtx.With(fields...)
+				var err error
+				switch {
+				case recovered != nil:
+					err = fmt.Errorf("panic: %v", recovered)
+					logCtx.Errorf("%s %s %d", r.Method, r.URL.Path, recorder.statusCode())
+				case recorder.statusCode() >= http.StatusInternalServerError:
+					err = fmt.Errorf("HTTP %d: %s", recorder.statusCode(), http.StatusText(recorder.statusCode()))
+					logCtx.Warnf("%s %s %d", r.Method, r.URL.Path, recorder.statusCode())
+				default:
+					logCtx.Infof("%s %s %d", r.Method, r.URL.Path, recorder.statusCode())
+				}
+				span.Close(err, closeOpts...)
+
+				if recovered != nil {
+					panic(recovered)
+				}
+			}()
+
+			next.ServeHTTP(recorder, served)
+		})
+	}
+}
+
+// routeResource returns the resource name of a request served by the route. The routes of http.ServeMux
+// may start with a method, which is replaced by the one of the request, eg. for HEAD requests to GET routes.
+func routeResource(method, route string) string {
+	if route == "" {
+		return method
+	}
+	if _, path, ok := strings.Cut(route, " "); ok {
+		route = strings.TrimLeft(path, " \t")
+	}
+	return method + " " + route
+}
+
+type httpAttemptsContextKey struct{}
+
+// WithHTTPAttempts returns a context counting the attempts of the outgoing requests made with it,
+// so that the spans of retried requests are tagged with their attempt number.
+// Use it for each logical request sent by a retrying HTTP client.
+func WithHTTPAttempts(ctx *Context) *Context {
+	return WithValue(ctx, httpAttemptsContextKey{}, new(atomic.Int64))
+}
+
+// HTTPRoundTripper wraps the RoundTripper, or http.DefaultTransport if nil, tracing outgoing requests.
+// It starts a client span for each request, using the context of the request, and injects the trace
+// context and the propagating fields into the request headers. The span is closed once the response
+// body is read or closed. Failed requests are logged, and transport errors, 5xx responses and failed
+// reads of the response body close the span with an error.
+func HTTPRoundTripper(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &roundTripper{base: base}
+}
+
+type roundTripper struct {
+	base http.RoundTripper
+}
+
+func (t *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := FromStdContext(req.Context())
+
+	fields := []interface{}{"http.method", req.Method, "http.host", req.URL.Host, "http.url", req.URL.Path}
+	if attempts, ok := ctx.Value(httpAttemptsContextKey{}).(*atomic.Int64); ok {
+		fields = append(fields, "http.attempt", attempts.Add(1))
+	}
+
+	spanCtx, span := ctx.StartSpan(
+		WithOperation("http.client.request"),
+		WithResource("%s %s", req.Method, req.URL.Host),
+		WithSpanKind(SpanKindClient),
+		WithTags(fields...),
+	)
+	logCtx := spanCtx.With(fields...)
+
+	// RoundTrippers must not modify the request, so the headers are injected into a copy.
+	out := req.Clone(spanCtx)
+	Inject(spanCtx, HeaderCarrier(out.Header))
+
+	resp, err := t.base.RoundTrip(out)
+	if err != nil {
+		logCtx.Warnf("HTTP request failed: %v", err)
+		span.Close(err)
+		return nil, err
+	}
+
+	span.SetTags("http.status_code", resp.StatusCode)
+
+	var statusErr error
+	if resp.StatusCode >= http.StatusInternalServerError {
+		logCtx.With("http.status_code", resp.StatusCode).Warnf("HTTP request failed with status %d", resp.StatusCode)
+		statusErr = fmt.Errorf("HTTP %d: %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+
+	if resp.Body == nil || resp.Body == http.NoBody {
+		span.Close(statusErr)
+		return resp, nil
+	}
+
+	// The span covers reading the response, so it's only closed once the body is read or closed.
+	body := &tracedBody{ReadCloser: resp.Body, closeSpan: func(readErr error) {
+		if statusErr == nil {
+			statusErr = readErr
+		}
+		span.Close(statusErr)
+	}}
+	resp.Body = body
+
+	// The bodies of 101 Switching Protocols responses are also writable.
+	if writer, ok := body.ReadCloser.(io.Writer); ok {
+		resp.Body = &tracedReadWriteBody{tracedBody: body, Writer: writer}
+	}
+
+	return resp, nil
+}
+
+// tracedBody closes the span of a response, with the read error if any, once its body is read or closed.
+type tracedBody struct {
+	io.ReadCloser
+	once      sync.Once
+	closeSpan func(readErr error)
+}
+
+func (b *tracedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		if err == io.EOF {
+			b.close(nil)
+		} else {
+			b.close(err)
+		}
+	}
+	return n, err
+}
+
+func (b *tracedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.close(nil)
+	return err
+}
+
+func (b *tracedBody) close(readErr error) {
+	b.once.Do(func() {
+		b.closeSpan(readErr)
+	})
+}
+
+// tracedReadWriteBody is a tracedBody which can also be written to.
+type tracedReadWriteBody struct {
+	*tracedBody
+	io.Writer
+}
+
+// responseRecorder records the status and size of a response.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	// Informational statuses, eg. 103 Early Hints, are followed by the final one, except for 101 Switching Protocols.
+	if r.status == 0 && (status >= http.StatusOK || status == http.StatusSwitchingProtocols) {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher, if the underlying writer does.
+func (r *responseRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		if r.status == 0 {
+			r.status = http.StatusOK
+		}
+		flusher.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker, if the underlying writer does.
+func (r *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	return hijacker.Hijack()
+}
+
+// Unwrap returns the underlying writer, for http.ResponseController.
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (r *responseRecorder) statusCode() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
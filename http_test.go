@@ -0,0 +1,241 @@
+package spcontext_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/spacelift-io/spcontext"
+)
+
+func TestHTTPMiddleware(t *testing.T) {
+	setup := func() (*recordingTracer, *bytes.Buffer, http.Handler) {
+		tracer := &recordingTracer{}
+		logBuffer := bytes.NewBuffer(nil)
+		ctx := spcontext.New(log.NewLogfmtLogger(logBuffer), spcontext.WithTracer(tracer))
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /stacks/{id}", func(w http.ResponseWriter, r *http.Request) {
+			spcontext.FromStdContext(r.Context()).Infof("getting stack")
+			_, _ = w.Write([]byte("stack"))
+		})
+		mux.HandleFunc("POST /stacks", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		})
+		mux.HandleFunc("GET /docs", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Link", "</style.css>; rel=preload; as=style")
+			w.WriteHeader(http.StatusEarlyHints)
+			w.WriteHeader(http.StatusInternalServerError)
+		})
+
+		return tracer, logBuffer, spcontext.HTTPMiddleware(ctx)(mux)
+	}
+
+	t.Run("successful request", func(t *testing.T) {
+		tracer, logBuffer, handler := setup()
+
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/stacks/my-stack", nil))
+
+		require.Len(t, tracer.started, 1)
+		assert.Equal(t, "http.request", tracer.started[0].Operation)
+		assert.Equal(t, spcontext.SpanKindServer, tracer.started[0].Kind)
+
+		require.Len(t, tracer.closed, 1)
+		assert.Equal(t, "GET /stacks/{id}", tracer.closed[0].Resource)
+		assert.NoError(t, tracer.errs[0])
+		assert.Subset(t, fieldMap(tracer.fields[0]), map[string]interface{}{
+			"http.status_code":    200,
+			"http.response_bytes": int64(5),
+			"http.route":          "GET /stacks/{id}",
+		})
+
+		assert.Contains(t, logBuffer.String(), `http.method=GET level=info msg="getting stack"`)
+		assert.Contains(t, logBuffer.String(), `http.status_code=200 http.request_bytes=0 http.response_bytes=5`)
+		assert.Contains(t, logBuffer.String(), `http.route="GET /stacks/{id}" level=info msg="GET /stacks/my-stack 200"`)
+	})
+
+	t.Run("server error", func(t *testing.T) {
+		tracer, logBuffer, handler := setup()
+
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/stacks", nil))
+
+		require.Len(t, tracer.closed, 1)
+		assert.EqualError(t, tracer.errs[0], "HTTP 502: Bad Gateway")
+		assert.Contains(t, logBuffer.String(), `level=warning msg="POST /stacks 502"`)
+	})
+
+	t.Run("server error after early hints", func(t *testing.T) {
+		tracer, logBuffer, handler := setup()
+
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/docs", nil))
+
+		require.Len(t, tracer.closed, 1)
+		assert.EqualError(t, tracer.errs[0], "HTTP 500: Internal Server Error")
+		assert.Equal(t, 500, fieldMap(tracer.fields[0])["http.status_code"])
+		assert.Contains(t, logBuffer.String(), `level=warning msg="GET /docs 500"`)
+	})
+
+	t.Run("route known upfront", func(t *testing.T) {
+		tracer := &recordingTracer{}
+		ctx := spcontext.New(log.NewNopLogger(), spcontext.WithTracer(tracer))
+		middleware := spcontext.HTTPMiddleware(ctx, spcontext.WithRouteFunc(func(*http.Request) string { return "/stacks/{id}" }))
+
+		middleware(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/stacks/my-stack", nil))
+
+		require.Len(t, tracer.started, 1)
+		assert.Equal(t, "GET /stacks/{id}", tracer.started[0].Resource)
+		assert.Equal(t, 404, fieldMap(tracer.fields[0])["http.status_code"])
+	})
+
+	t.Run("route of a wrapped handler", func(t *testing.T) {
+		tracer := &recordingTracer{}
+		ctx := spcontext.New(log.NewNopLogger(), spcontext.WithTracer(tracer))
+
+		mux := http.NewServeMux()
+		mux.Handle("GET /stacks/{id}", spcontext.HTTPMiddleware(ctx)(http.NotFoundHandler()))
+
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodHead, "/stacks/my-stack", nil))
+
+		require.Len(t, tracer.started, 1)
+		assert.Equal(t, "HEAD /stacks/{id}", tracer.started[0].Resource)
+		assert.Empty(t, tracer.closed[0].Resource)
+	})
+
+	t.Run("hijacked connection", func(t *testing.T) {
+		ctx := spcontext.New(log.NewNopLogger())
+		server := httptest.NewServer(spcontext.HTTPMiddleware(ctx)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hijacker, ok := w.(http.Hijacker)
+			if !assert.True(t, ok, "websocket libraries hijack connections with a type assertion") {
+				return
+			}
+			conn, buf, err := hijacker.Hijack()
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer conn.Close()
+
+			_, _ = buf.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+			_ = buf.Flush()
+		})))
+		defer server.Close()
+
+		resp, err := http.Get(server.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "hijacked", string(body))
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		tracer := &recordingTracer{}
+		ctx := spcontext.New(log.NewNopLogger(), spcontext.WithTracer(tracer))
+		handler := spcontext.HTTPMiddleware(ctx)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			panic("boom")
+		}))
+
+		assert.PanicsWithValue(t, "boom", func() {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		})
+
+		require.Len(t, tracer.closed, 1)
+		assert.EqualError(t, tracer.errs[0], "panic: boom")
+		assert.Equal(t, 500, fieldMap(tracer.fields[0])["http.status_code"])
+	})
+}
+
+func TestHTTPRoundTripper(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Received-Parent", r.Header.Get("Test-Parent"))
+		switch r.URL.Path {
+		case "/fail":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/logs":
+			_, _ = w.Write([]byte("plan started"))
+		}
+	}))
+	defer server.Close()
+
+	setup := func() (*spcontext.Context, *propagatingTracer, *bytes.Buffer) {
+		tracer := &propagatingTracer{}
+		logBuffer := bytes.NewBuffer(nil)
+		return spcontext.New(log.NewLogfmtLogger(logBuffer), spcontext.WithTracer(tracer)), tracer, logBuffer
+	}
+	client := &http.Client{Transport: spcontext.HTTPRoundTripper(nil)}
+
+	t.Run("successful request", func(t *testing.T) {
+		ctx, tracer, logBuffer := setup()
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/stacks", nil)
+
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, "parent", resp.Header.Get("Received-Parent"))
+		assert.Empty(t, req.Header, "the original request shouldn't be modified")
+		assert.Empty(t, logBuffer.String())
+
+		require.Len(t, tracer.started, 1)
+		assert.Equal(t, spcontext.SpanKindClient, tracer.started[0].Kind)
+		assert.Equal(t, "GET "+req.URL.Host, tracer.started[0].Resource)
+		require.Len(t, tracer.closed, 1)
+		assert.NoError(t, tracer.errs[0])
+		assert.Equal(t, 200, fieldMap(tracer.fields[0])["http.status_code"])
+	})
+
+	t.Run("closes the span once the body is read", func(t *testing.T) {
+		ctx, tracer, _ := setup()
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/logs", nil)
+
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		assert.Empty(t, tracer.closed)
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "plan started", string(body))
+		assert.Len(t, tracer.closed, 1)
+
+		resp.Body.Close()
+		require.Len(t, tracer.closed, 1, "the span should only be closed once")
+		assert.NoError(t, tracer.errs[0])
+	})
+
+	t.Run("retried server errors", func(t *testing.T) {
+		ctx, tracer, logBuffer := setup()
+		ctx = spcontext.WithHTTPAttempts(ctx)
+
+		for i := 0; i < 2; i++ {
+			req, _ := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/fail", nil)
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+		}
+
+		require.Len(t, tracer.closed, 2)
+		for i, err := range tracer.errs {
+			assert.EqualError(t, err, "HTTP 503: Service Unavailable")
+			assert.Equal(t, int64(i+1), fieldMap(tracer.fields[i])["http.attempt"])
+		}
+		assert.Contains(t, logBuffer.String(), `http.attempt=2 http.status_code=503 level=warning msg="HTTP request failed with status 503"`)
+	})
+
+	t.Run("transport error", func(t *testing.T) {
+		ctx, tracer, logBuffer := setup()
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://127.0.0.1:1/stacks", nil)
+
+		_, err := client.Do(req)
+		require.Error(t, err)
+
+		require.Len(t, tracer.closed, 1)
+		assert.Error(t, tracer.errs[0])
+		assert.Contains(t, logBuffer.String(), `http.method=GET http.host=127.0.0.1:1 http.url=/stacks level=warning msg="HTTP request failed: `)
+	})
+}
@@ -0,0 +1,34 @@
+package internal
+
+// DeduplicateFields removes duplicate fields from the given slice of fields.
+func DeduplicateFields(fields []any) map[string]any {
+	out := make(map[string]interface{})
+
+	for i := 0; i < len(fields)/2; i++ {
+		key := fields[2*i].(string)
+		value := fields[2*i+1]
+		out[key] = value
+	}
+
+	return out
+}
+
+// DeduplicateFieldList removes duplicate fields from the given slice of fields, keeping the order.
+// Each key stays in the position of its first occurrence, with the value of its last one.
+func DeduplicateFieldList(fields []any) []any {
+	positions := make(map[string]int, len(fields)/2)
+	out := make([]any, 0, len(fields))
+
+	for i := 0; i < len(fields)/2; i++ {
+		key := fields[2*i].(string)
+		value := fields[2*i+1]
+		if position, ok := positions[key]; ok {
+			out[position+1] = value
+			continue
+		}
+		positions[key] = len(out)
+		out = append(out, key, value)
+	}
+
+	return out
+}
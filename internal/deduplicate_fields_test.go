@@ -0,0 +1,17 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDeduplicateFieldList(t *testing.T) {
+	fields := []any{"stack", "ctx-stack", "run", 1, "stack", "err-stack", "exit_code", 2}
+
+	deduplicated := DeduplicateFieldList(fields)
+
+	expected := []any{"stack", "err-stack", "run", 1, "exit_code", 2}
+	if !reflect.DeepEqual(deduplicated, expected) {
+		t.Errorf("expected deduplicated fields to be %v, got %v", expected, deduplicated)
+	}
+}
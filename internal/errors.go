@@ -0,0 +1,38 @@
+package internal
+
+import "errors"
+
+// UnwrapError returns the root cause of the given error. For multi-errors, such as the
+// ones created by errors.Join, the root cause of the first branch is returned.
+func UnwrapError(err error) error {
+	causes := UnwrapErrors(err)
+	if len(causes) == 0 {
+		return nil
+	}
+	return causes[0]
+}
+
+// UnwrapErrors returns all the root causes of the given error, walking multi-errors
+// implementing Unwrap() []error as trees.
+func UnwrapErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+
+	if multi, ok := err.(interface{ Unwrap() []error }); ok {
+		var out []error
+		for _, branch := range multi.Unwrap() {
+			out = append(out, UnwrapErrors(branch)...)
+		}
+		if len(out) == 0 {
+			return []error{err}
+		}
+		return out
+	}
+
+	if unwrapped := errors.Unwrap(err); unwrapped != nil {
+		return UnwrapErrors(unwrapped)
+	}
+
+	return []error{err}
+}
@@ -1,6 +1,8 @@
 package internal
 
 import (
+	stderrors "errors"
+	"fmt"
 	"testing"
 
 	"github.com/pkg/errors"
@@ -34,3 +36,20 @@ func TestUnwrap_Nil(t *testing.T) {
 		t.Errorf("expected unwrapped error to be nil, got %v", unwrapped)
 	}
 }
+
+func TestUnwrap_Joined(t *testing.T) {
+	err1 := errors.New("error 1")
+	err2 := errors.New("error 2")
+	err3 := errors.New("error 3")
+	joined := errors.Wrap(stderrors.Join(errors.Wrap(err1, "wrap1"), stderrors.Join(err2, fmt.Errorf("wrap3: %w", err3))), "outer")
+
+	unwrapped := UnwrapError(joined)
+	if unwrapped != err1 {
+		t.Errorf("expected unwrapped error to be %v, got %v", err1, unwrapped)
+	}
+
+	causes := UnwrapErrors(joined)
+	if len(causes) != 3 || causes[0] != err1 || causes[1] != err2 || causes[2] != err3 {
+		t.Errorf("expected causes to be [%v %v %v], got %v", err1, err2, err3, causes)
+	}
+}
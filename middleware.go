@@ -4,47 +4,33 @@ import (
 	"context"
 	"net/http"
 	"time"
-
-	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
-	"google.golang.org/grpc"
 )
 
 // ContextInjector injects the given context into each request.
 // Swapping the underlying context.Context for the one in the request.
 func ContextInjector(ctx *Context) func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 	return func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
-		next(w, r.WithContext(&Context{
-			Context:          &mergeValuesContext{base: r.Context(), merged: ctx.Context},
-			fields:           ctx.fields,
-			logger:           ctx.logger,
-			logLevel:         ctx.logLevel,
-			Notifier:         ctx.Notifier,
-			Tracer:           ctx.Tracer,
-			onSpanStartHooks: ctx.onSpanStartHooks,
-		}))
+		next(w, r.WithContext(ExtractBaggage(ctx.injectInto(r.Context()), HeaderCarrier(r.Header))))
 	}
 }
 
-// GRPCStreamContextInjector injects the given context into each stream.
-// Swapping the underlying context.Context for the one in the request.
-func GRPCStreamContextInjector(ctx *Context) grpc.StreamServerInterceptor {
-	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
-		newCtx := &Context{
-			Context:          &mergeValuesContext{base: stream.Context(), merged: ctx.Context},
-			fields:           ctx.fields,
-			logger:           ctx.logger,
-			logLevel:         ctx.logLevel,
-			Notifier:         ctx.Notifier,
-			Tracer:           ctx.Tracer,
-			onSpanStartHooks: ctx.onSpanStartHooks,
-		}
-		wrappedStream := grpc_middleware.WrapServerStream(stream)
-		wrappedStream.WrappedContext = newCtx
-		return handler(srv, wrappedStream)
+// injectInto returns the context with its underlying context.Context swapped for the given one,
+// falling back to the context for values.
+func (ctx *Context) injectInto(base context.Context) *Context {
+	return &Context{
+		Context:          &mergeValuesContext{base: base, merged: context.WithoutCancel(ctx.Context)},
+		fields:           ctx.fields,
+		logger:           ctx.logger,
+		logLevel:         ctx.logLevel,
+		Notifier:         ctx.Notifier,
+		Tracer:           ctx.Tracer,
+		onSpanStartHooks: ctx.onSpanStartHooks,
+		config:           ctx.config,
 	}
 }
 
 // mergeValuesContext merges values from two contexts, with other properties being based on the base one.
+// The merged context must not be cancelable, so that context.Cause can't return the cause of its cancellation.
 // Can be removed when this proposal gets implemented: https://github.com/golang/go/issues/36503
 type mergeValuesContext struct {
 	base, merged context.Context
@@ -0,0 +1,257 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spacelift-io/spcontext"
+)
+
+// ErrQueueFull is returned by the asynchronous notifier when a notification is dropped
+// because the queue is full.
+var ErrQueueFull = errors.New("notification queue is full")
+
+// ErrClosed is returned by the asynchronous notifier when a notification is sent after it was closed.
+var ErrClosed = errors.New("notifier is closed")
+
+// AsyncOption is used to optionally configure the asynchronous notifier on creation.
+type AsyncOption func(a *Async)
+
+// WithQueueSize sets the maximum number of pending notifications. Defaults to 256.
+func WithQueueSize(size int) AsyncOption {
+	return func(a *Async) {
+		a.queueSize = size
+	}
+}
+
+// WithWorkers sets the number of goroutines sending notifications. Defaults to 1, lower values are treated as 1.
+func WithWorkers(workers int) AsyncOption {
+	return func(a *Async) {
+		a.workers = workers
+	}
+}
+
+// WithRetries sets how many times a failed notification is retried, and the initial
+// and maximum backoff between attempts. The backoff doubles after each attempt.
+// Defaults to 3 retries with 100ms initial and 5s maximum backoff.
+func WithRetries(retries int, initialBackoff, maxBackoff time.Duration) AsyncOption {
+	return func(a *Async) {
+		a.retries = retries
+		a.initialBackoff = initialBackoff
+		a.maxBackoff = maxBackoff
+	}
+}
+
+// WithErrorHandler sets a function called with the error of each notification which
+// could not be sent after all the retries.
+func WithErrorHandler(handler func(error)) AsyncOption {
+	return func(a *Async) {
+		a.onError = handler
+	}
+}
+
+// Async is a Notifier sending notifications in the background, so that slow exception
+// trackers don't add latency to the code reporting errors. Notifications are kept in a bounded
+// in-memory queue, so Flush or Close should be called before the process exits.
+type Async struct {
+	notifier spcontext.Notifier
+
+	queueSize, workers, retries int
+	initialBackoff, maxBackoff  time.Duration
+	onError                     func(error)
+
+	queue   chan *spcontext.Report
+	dropped atomic.Uint64
+
+	mu      sync.Mutex
+	closed  bool
+	pending int
+	idle    chan struct{}
+
+	stop    chan struct{}
+	stopped sync.WaitGroup
+}
+
+// NewAsync creates a new asynchronous Notifier sending notifications to the given notifier.
+func NewAsync(notifier spcontext.Notifier, opts ...AsyncOption) *Async {
+	a := &Async{
+		notifier:       notifier,
+		queueSize:      256,
+		workers:        1,
+		retries:        3,
+		initialBackoff: 100 * time.Millisecond,
+		maxBackoff:     5 * time.Second,
+		onError:        func(error) {},
+		stop:           make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	// Without workers the queue would never be processed, and flushing would block forever.
+	a.workers = max(a.workers, 1)
+	a.queue = make(chan *spcontext.Report, a.queueSize)
+
+	a.stopped.Add(a.workers)
+	for i := 0; i < a.workers; i++ {
+		go a.work()
+	}
+
+	return a
+}
+
+// Notify queues the notification. It returns ErrQueueFull if the notification was dropped.
+func (a *Async) Notify(err error, rawData ...interface{}) error {
+	return a.NotifyReport(spcontext.ReportFromBugsnag(err, rawData...))
+}
+
+// NotifyReport queues the report. It returns ErrQueueFull if the report was dropped.
+func (a *Async) NotifyReport(report *spcontext.Report) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		a.dropped.Add(1)
+		return ErrClosed
+	}
+
+	select {
+	case a.queue <- report:
+		a.pending++
+		return nil
+	default:
+		a.dropped.Add(1)
+		return ErrQueueFull
+	}
+}
+
+// AutoNotify synchronously notifies about a panic and re-panics.
+// Usage: defer notifier.AutoNotify(ctx)
+func (a *Async) AutoNotify(rawData ...interface{}) {
+	if recovered := recover(); recovered != nil {
+		notifyPanic(notifierFunc(func(report *spcontext.Report) error {
+			return spcontext.NotifyReport(a.notifier, report)
+		}), recovered, rawData)
+		panic(recovered)
+	}
+}
+
+// Dropped returns the number of notifications which were dropped, either because the queue
+// was full or because they could not be sent after all the retries.
+func (a *Async) Dropped() uint64 {
+	return a.dropped.Load()
+}
+
+// Flush waits until all the queued notifications are processed or the context is done.
+func (a *Async) Flush(ctx context.Context) error {
+	a.mu.Lock()
+	if a.pending == 0 {
+		a.mu.Unlock()
+		return nil
+	}
+	if a.idle == nil {
+		a.idle = make(chan struct{})
+	}
+	idle := a.idle
+	a.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting new notifications, flushes the queued ones and stops the workers.
+// Workers are stopped even if the context is done before the queue is flushed,
+// in which case the notifications left in the queue are dropped.
+func (a *Async) Close(ctx context.Context) error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil
+	}
+	a.closed = true
+	a.mu.Unlock()
+
+	err := a.Flush(ctx)
+
+	close(a.stop)
+	a.stopped.Wait()
+
+	for {
+		select {
+		case <-a.queue:
+			a.dropped.Add(1)
+			a.done()
+		default:
+			return err
+		}
+	}
+}
+
+func (a *Async) work() {
+	defer a.stopped.Done()
+
+	for {
+		// Stopped workers must not pick up queued notifications, even if they're ready to be received.
+		select {
+		case <-a.stop:
+			return
+		default:
+		}
+
+		select {
+		case report := <-a.queue:
+			a.send(report)
+			a.done()
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+func (a *Async) send(report *spcontext.Report) {
+	backoff := a.initialBackoff
+
+	for attempt := 0; ; attempt++ {
+		err := spcontext.NotifyReport(a.notifier, report)
+		if err == nil {
+			return
+		}
+
+		if attempt >= a.retries {
+			a.dropped.Add(1)
+			a.onError(err)
+			return
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-a.stop:
+			a.dropped.Add(1)
+			a.onError(err)
+			return
+		}
+
+		if backoff *= 2; backoff > a.maxBackoff {
+			backoff = a.maxBackoff
+		}
+	}
+}
+
+func (a *Async) done() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.pending--
+	if a.pending == 0 && a.idle != nil {
+		close(a.idle)
+		a.idle = nil
+	}
+}
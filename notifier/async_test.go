@@ -0,0 +1,132 @@
+package notifier_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/spacelift-io/spcontext/notifier"
+	"github.com/spacelift-io/spcontext/testutils"
+)
+
+func TestAsync(t *testing.T) {
+	t.Run("retries and flushes", func(t *testing.T) {
+		backend := new(testutils.MockNotifier)
+		backend.On("Notify", mock.Anything, mock.Anything).Return(errors.New("unavailable")).Once()
+		backend.On("Notify", mock.Anything, mock.Anything).Return(nil)
+
+		async := notifier.NewAsync(backend, notifier.WithRetries(1, time.Millisecond, time.Millisecond))
+
+		require.NoError(t, async.Notify(errors.New("boom")))
+		require.NoError(t, async.Flush(context.Background()))
+
+		backend.AssertNumberOfCalls(t, "Notify", 2)
+		assert.Zero(t, async.Dropped())
+		require.NoError(t, async.Close(context.Background()))
+	})
+
+	t.Run("drops after retries", func(t *testing.T) {
+		backend := new(testutils.MockNotifier)
+		backend.On("Notify", mock.Anything, mock.Anything).Return(errors.New("unavailable"))
+
+		var handled []error
+		async := notifier.NewAsync(
+			backend,
+			notifier.WithRetries(2, time.Millisecond, time.Millisecond),
+			notifier.WithErrorHandler(func(err error) { handled = append(handled, err) }),
+		)
+
+		require.NoError(t, async.Notify(errors.New("boom")))
+		require.NoError(t, async.Close(context.Background()))
+
+		backend.AssertNumberOfCalls(t, "Notify", 3)
+		assert.EqualValues(t, 1, async.Dropped())
+		assert.Len(t, handled, 1)
+	})
+
+	t.Run("drops when queue is full", func(t *testing.T) {
+		started, release := make(chan struct{}, 2), make(chan struct{})
+		backend := new(testutils.MockNotifier)
+		backend.On("Notify", mock.Anything, mock.Anything).Run(func(mock.Arguments) {
+			started <- struct{}{}
+			<-release
+		}).Return(nil)
+
+		async := notifier.NewAsync(backend, notifier.WithQueueSize(1))
+
+		// The first notification is picked up by the worker, the second one fills the queue.
+		require.NoError(t, async.Notify(errors.New("first")))
+		<-started
+		require.NoError(t, async.Notify(errors.New("second")))
+
+		assert.ErrorIs(t, async.Notify(errors.New("third")), notifier.ErrQueueFull)
+		assert.EqualValues(t, 1, async.Dropped())
+
+		close(release)
+		require.NoError(t, async.Close(context.Background()))
+		backend.AssertNumberOfCalls(t, "Notify", 2)
+	})
+
+	t.Run("flush respects context", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+
+		backend := new(testutils.MockNotifier)
+		backend.On("Notify", mock.Anything, mock.Anything).Run(func(mock.Arguments) { <-release }).Return(nil)
+
+		async := notifier.NewAsync(backend)
+		require.NoError(t, async.Notify(errors.New("boom")))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		assert.ErrorIs(t, async.Flush(ctx), context.DeadlineExceeded)
+	})
+
+	t.Run("drops queued notifications when close times out", func(t *testing.T) {
+		started, release := make(chan struct{}), make(chan struct{})
+		backend := new(testutils.MockNotifier)
+		backend.On("Notify", mock.Anything, mock.Anything).Run(func(mock.Arguments) {
+			close(started)
+			<-release
+		}).Return(nil).Once()
+
+		async := notifier.NewAsync(backend)
+		for i := 0; i < 3; i++ {
+			require.NoError(t, async.Notify(errors.New("boom")))
+		}
+		<-started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		time.AfterFunc(20*time.Millisecond, func() { close(release) })
+
+		assert.ErrorIs(t, async.Close(ctx), context.DeadlineExceeded)
+		backend.AssertNumberOfCalls(t, "Notify", 1)
+		assert.EqualValues(t, 2, async.Dropped())
+		assert.NoError(t, async.Flush(context.Background()))
+	})
+
+	t.Run("uses at least one worker", func(t *testing.T) {
+		backend := new(testutils.MockNotifier)
+		backend.On("Notify", mock.Anything, mock.Anything).Return(nil)
+
+		async := notifier.NewAsync(backend, notifier.WithWorkers(0))
+		require.NoError(t, async.Notify(errors.New("boom")))
+		require.NoError(t, async.Close(context.Background()))
+
+		backend.AssertNumberOfCalls(t, "Notify", 1)
+	})
+
+	t.Run("rejects notifications after close", func(t *testing.T) {
+		async := notifier.NewAsync(new(testutils.MockNotifier))
+		require.NoError(t, async.Close(context.Background()))
+
+		assert.ErrorIs(t, async.Notify(errors.New("boom")), notifier.ErrClosed)
+	})
+}
@@ -0,0 +1,90 @@
+package notifier
+
+import (
+	"fmt"
+
+	"github.com/spacelift-io/spcontext"
+)
+
+// BeforeNotifyFunc is called before a report is sent. It may modify the report,
+// or return false to drop it altogether.
+type BeforeNotifyFunc func(report *spcontext.Report) bool
+
+// beforeNotify is a Notifier calling hooks before passing the report on.
+type beforeNotify struct {
+	notifier spcontext.Notifier
+	hooks    []BeforeNotifyFunc
+}
+
+// WithBeforeNotify creates a new Notifier calling the hooks, in order, before each report.
+func WithBeforeNotify(notifier spcontext.Notifier, hooks ...BeforeNotifyFunc) spcontext.Notifier {
+	return &beforeNotify{notifier: notifier, hooks: hooks}
+}
+
+// Notify runs the hooks and passes the notification on, unless it was dropped.
+func (b *beforeNotify) Notify(err error, rawData ...interface{}) error {
+	return b.NotifyReport(spcontext.ReportFromBugsnag(err, rawData...))
+}
+
+// NotifyReport runs the hooks on a copy of the report and passes it on, unless it was dropped.
+func (b *beforeNotify) NotifyReport(report *spcontext.Report) error {
+	report = report.Clone()
+	for _, hook := range b.hooks {
+		if !hook(report) {
+			return nil
+		}
+	}
+	return spcontext.NotifyReport(b.notifier, report)
+}
+
+// AutoNotify notifies about a panic and re-panics.
+// Usage: defer notifier.AutoNotify(ctx)
+func (b *beforeNotify) AutoNotify(rawData ...interface{}) {
+	if recovered := recover(); recovered != nil {
+		notifyPanic(b, recovered, rawData)
+		panic(recovered)
+	}
+}
+
+// DropIf returns a hook dropping the reports matching the given matcher.
+func DropIf(match Matcher) BeforeNotifyFunc {
+	return func(report *spcontext.Report) bool {
+		return !match(report)
+	}
+}
+
+// AddFields returns a hook adding the given alternating keys and values to the fields of each report.
+// Panics if odd number of arguments were passed or key(first value of each pair) is not a string.
+func AddFields(kvs ...interface{}) BeforeNotifyFunc {
+	if len(kvs)%2 != 0 {
+		panic("invalid AddFields call: odd number of arguments")
+	}
+	for i := 0; i < len(kvs); i += 2 {
+		if _, ok := kvs[i].(string); !ok {
+			panic(fmt.Sprintf("invalid AddFields call: non-string field key: %v", kvs[i]))
+		}
+	}
+
+	return func(report *spcontext.Report) bool {
+		if report.Fields == nil {
+			report.Fields = make(map[string]interface{}, len(kvs)/2)
+		}
+		for i := 0; i < len(kvs); i += 2 {
+			report.Fields[kvs[i].(string)] = kvs[i+1]
+		}
+		return true
+	}
+}
+
+// AddTags returns a hook adding the given tags to each report.
+func AddTags(tags map[string]string) BeforeNotifyFunc {
+	return func(report *spcontext.Report) bool {
+		if report.Tags == nil {
+			report.Tags = make(map[string]string, len(tags))
+		}
+		for key, value := range tags {
+			report.Tags[key] = value
+		}
+		return true
+	}
+}
@@ -0,0 +1,40 @@
+package notifier
+
+import (
+	"errors"
+
+	"github.com/spacelift-io/spcontext"
+)
+
+// multinotifier is a Notifier which notifies all the given notifiers.
+type multinotifier []spcontext.Notifier
+
+// Multi creates a new Notifier which will send notifications to all the given notifiers.
+func Multi(notifiers ...spcontext.Notifier) spcontext.Notifier {
+	return multinotifier(notifiers)
+}
+
+// Notify sends the notification to all the notifiers, returning all the errors they reported.
+func (m multinotifier) Notify(err error, rawData ...interface{}) error {
+	return m.NotifyReport(spcontext.ReportFromBugsnag(err, rawData...))
+}
+
+// NotifyReport sends the report to all the notifiers, returning all the errors they reported.
+func (m multinotifier) NotifyReport(report *spcontext.Report) error {
+	var errs []error
+	for _, notifier := range m {
+		if notifyErr := spcontext.NotifyReport(notifier, report); notifyErr != nil {
+			errs = append(errs, notifyErr)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// AutoNotify notifies all the notifiers about a panic and re-panics.
+// Usage: defer notifier.AutoNotify(ctx)
+func (m multinotifier) AutoNotify(rawData ...interface{}) {
+	if recovered := recover(); recovered != nil {
+		notifyPanic(m, recovered, rawData)
+		panic(recovered)
+	}
+}
@@ -0,0 +1,77 @@
+// Package notifier contains building blocks for composing spcontext Notifiers:
+// fanning notifications out to multiple backends, filtering and mutating them
+// before they're sent, and routing them based on their contents.
+//
+// All the notifiers in this package work on backend-neutral spcontext Reports. Notifications
+// sent using the Bugsnag-compatible Notify method are converted into reports first.
+package notifier
+
+import (
+	"errors"
+	"reflect"
+
+	bugsnagerrors "github.com/bugsnag/bugsnag-go/v2/errors"
+
+	"github.com/spacelift-io/spcontext"
+)
+
+// Matcher tells whether a report matches some criteria.
+type Matcher func(report *spcontext.Report) bool
+
+// ErrorIs matches reports for errors which match the target according to errors.Is.
+func ErrorIs(target error) Matcher {
+	return func(report *spcontext.Report) bool {
+		return errors.Is(report.Error, target)
+	}
+}
+
+// ErrorAs matches reports for errors which have an error of type T in their tree.
+func ErrorAs[T error]() Matcher {
+	return func(report *spcontext.Report) bool {
+		var target T
+		return errors.As(report.Error, &target)
+	}
+}
+
+// ErrorClass matches reports with the given error class.
+func ErrorClass(name string) Matcher {
+	return func(report *spcontext.Report) bool {
+		return report.ErrorClass == name
+	}
+}
+
+// FieldEquals matches reports with the given field set to the given value.
+// Values are compared deeply, so that slices and maps can be matched too.
+func FieldEquals(key string, value interface{}) Matcher {
+	return func(report *spcontext.Report) bool {
+		fieldValue, ok := report.Fields[key]
+		return ok && reflect.DeepEqual(fieldValue, value)
+	}
+}
+
+// HasField matches reports with the given field set.
+func HasField(key string) Matcher {
+	return func(report *spcontext.Report) bool {
+		_, ok := report.Fields[key]
+		return ok
+	}
+}
+
+// notifierFunc adapts a function into a ReportNotifier.
+type notifierFunc func(report *spcontext.Report) error
+
+// NotifyReport calls the function.
+func (f notifierFunc) NotifyReport(report *spcontext.Report) error {
+	return f(report)
+}
+
+// notifyPanic notifies about a recovered panic, the same way Bugsnag's AutoNotify does.
+// It has to be called from within a deferred AutoNotify, so that the panic can be recovered.
+func notifyPanic(notifier spcontext.ReportNotifier, recovered interface{}, rawData []interface{}) {
+	// We skip the frames of notifyPanic and AutoNotify.
+	report := spcontext.ReportFromBugsnag(bugsnagerrors.New(recovered, 3), rawData...)
+	report.Severity = spcontext.SeverityError
+	report.Unhandled = true
+
+	_ = notifier.NotifyReport(report)
+}
@@ -0,0 +1,170 @@
+package notifier_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bugsnag/bugsnag-go/v2"
+	"github.com/go-kit/log"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/spacelift-io/spcontext"
+	"github.com/spacelift-io/spcontext/notifier"
+	"github.com/spacelift-io/spcontext/testutils"
+)
+
+var errNoise = errors.New("known noise")
+
+func newMockNotifier() *testutils.MockNotifier {
+	out := new(testutils.MockNotifier)
+	out.On("Notify", mock.Anything, mock.Anything).Return(nil)
+	return out
+}
+
+func TestMulti(t *testing.T) {
+	first, second := newMockNotifier(), newMockNotifier()
+	ctx := spcontext.New(log.NewNopLogger(), spcontext.WithNotifier(notifier.Multi(first, second)))
+
+	_ = ctx.InternalError(errors.New("boom"), "could not do it")
+
+	first.AssertNumberOfCalls(t, "Notify", 1)
+	second.AssertNumberOfCalls(t, "Notify", 1)
+}
+
+func TestMulti_Errors(t *testing.T) {
+	failing := new(testutils.MockNotifier)
+	failing.On("Notify", mock.Anything, mock.Anything).Return(errors.New("unavailable"))
+	working := newMockNotifier()
+
+	err := notifier.Multi(failing, working).Notify(errors.New("boom"))
+
+	assert.EqualError(t, err, "unavailable")
+	working.AssertNumberOfCalls(t, "Notify", 1)
+}
+
+func TestWithBeforeNotify(t *testing.T) {
+	backend := newMockNotifier()
+	ctx := spcontext.New(log.NewNopLogger(), spcontext.WithNotifier(notifier.WithBeforeNotify(
+		backend,
+		notifier.DropIf(notifier.ErrorIs(errNoise)),
+		notifier.AddFields("release", "1.2.3"),
+	)))
+
+	_ = ctx.InternalError(errNoise, "noisy")
+	backend.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything)
+
+	_ = ctx.InternalError(errors.New("boom"), "could not do it")
+	backend.AssertNumberOfCalls(t, "Notify", 1)
+
+	rawData := backend.Calls[0].Arguments.Get(1).([]interface{})
+	assert.Equal(t, "1.2.3", rawData[0].(bugsnag.MetaData)[spcontext.FieldsTab]["release"])
+}
+
+func TestWithBeforeNotify_DoesNotModifyOriginal(t *testing.T) {
+	backend := new(testutils.MockReportNotifier)
+	backend.On("NotifyReport", mock.Anything).Return(nil)
+
+	report := &spcontext.Report{Error: errors.New("boom"), Fields: map[string]interface{}{"key": "value"}}
+	require.NoError(t, notifier.WithBeforeNotify(backend, notifier.AddFields("new", 1)).(spcontext.ReportNotifier).NotifyReport(report))
+
+	assert.Equal(t, map[string]interface{}{"key": "value"}, report.Fields)
+	assert.Equal(t, map[string]interface{}{"key": "value", "new": 1}, backend.Calls[0].Arguments.Get(0).(*spcontext.Report).Fields)
+}
+
+func TestRouter(t *testing.T) {
+	billing, fallback := newMockNotifier(), newMockNotifier()
+	ctx := spcontext.New(log.NewNopLogger(), spcontext.WithNotifier(notifier.Router(
+		fallback,
+		notifier.Route{Match: notifier.FieldEquals("team", "billing"), Notifier: billing},
+	)))
+
+	_ = ctx.With("team", "billing").InternalError(errors.New("boom"), "could not charge")
+	billing.AssertNumberOfCalls(t, "Notify", 1)
+	fallback.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything)
+
+	_ = ctx.With("team", "runs").InternalError(errors.New("boom"), "could not run")
+	billing.AssertNumberOfCalls(t, "Notify", 1)
+	fallback.AssertNumberOfCalls(t, "Notify", 1)
+}
+
+func TestRouter_MetadataTabs(t *testing.T) {
+	requests, fallback := newMockNotifier(), newMockNotifier()
+	ctx := spcontext.New(
+		log.NewNopLogger(),
+		spcontext.WithMetadataTab("request", "request_id"),
+		spcontext.WithNotifier(notifier.Router(
+			fallback,
+			notifier.Route{Match: notifier.HasField("request.route"), Notifier: requests},
+		)),
+	)
+
+	// Fields routed into metadata tabs can still be matched.
+	_ = ctx.With("request.route", "/stacks/{id}").InternalError(errors.New("boom"), "could not get stack")
+	requests.AssertNumberOfCalls(t, "Notify", 1)
+	fallback.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything)
+}
+
+func TestFieldEquals(t *testing.T) {
+	report := &spcontext.Report{Fields: map[string]interface{}{
+		"team":   "billing",
+		"causes": []string{"timeout", "forbidden"},
+	}}
+
+	assert.True(t, notifier.FieldEquals("team", "billing")(report))
+	assert.False(t, notifier.FieldEquals("team", "runs")(report))
+	assert.True(t, notifier.FieldEquals("causes", []string{"timeout", "forbidden"})(report))
+	assert.False(t, notifier.FieldEquals("causes", []string{"timeout"})(report))
+	assert.False(t, notifier.FieldEquals("missing", nil)(report))
+}
+
+type planError struct {
+	err error
+}
+
+func (e *planError) Error() string { return "planning failed: " + e.err.Error() }
+
+func (e *planError) Unwrap() error { return e.err }
+
+func TestRouter_ErrorTypes(t *testing.T) {
+	plans, fallback := newMockNotifier(), newMockNotifier()
+	ctx := spcontext.New(log.NewNopLogger(), spcontext.WithNotifier(notifier.WithBeforeNotify(
+		notifier.Router(
+			fallback,
+			notifier.Route{Match: notifier.ErrorAs[*planError](), Notifier: plans},
+		),
+		notifier.DropIf(notifier.ErrorIs(errNoise)),
+	)))
+
+	// The stack trace is carried by the wrapped error, which must not hide the type wrapping it.
+	_ = ctx.InternalError(&planError{err: pkgerrors.New("exit status 1")}, "could not plan")
+	plans.AssertNumberOfCalls(t, "Notify", 1)
+	fallback.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything)
+
+	// Branches with a stack trace must not hide their siblings.
+	_ = ctx.InternalError(errors.Join(pkgerrors.New("with stack"), errNoise), "could not run")
+	plans.AssertNumberOfCalls(t, "Notify", 1)
+	fallback.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything)
+}
+
+func TestRouter_NoFallback(t *testing.T) {
+	err := notifier.Router(nil).Notify(errors.New("boom"))
+
+	assert.NoError(t, err)
+}
+
+func TestAutoNotify(t *testing.T) {
+	first, second := newMockNotifier(), newMockNotifier()
+	multi := notifier.Multi(first, second)
+
+	require.PanicsWithValue(t, "oh no", func() {
+		defer multi.AutoNotify()
+		panic("oh no")
+	})
+
+	first.AssertNumberOfCalls(t, "Notify", 1)
+	second.AssertNumberOfCalls(t, "Notify", 1)
+	assert.EqualError(t, first.Calls[0].Arguments.Error(0), "oh no")
+}
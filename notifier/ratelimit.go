@@ -0,0 +1,267 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spacelift-io/spcontext"
+)
+
+// SuppressedField is the field holding the number of similar notifications suppressed
+// by the rate limiter since the previous one was sent.
+const SuppressedField = "suppressed_similar"
+
+// GroupingKeyField is the field used by the rate limiter, alongside the error class and
+// the caller, to tell whether notifications are similar.
+const GroupingKeyField = "grouping_key"
+
+// KeyFunc returns the key used to group similar reports.
+type KeyFunc func(report *spcontext.Report) string
+
+// DefaultKey groups reports by their error class, grouping key and caller.
+func DefaultKey(report *spcontext.Report) string {
+	return fmt.Sprintf("%s|%v|%v", report.ErrorClass, report.Fields[GroupingKeyField], report.Fields["caller"])
+}
+
+// RateLimitOption is used to optionally configure the rate limiter on creation.
+type RateLimitOption func(r *RateLimiter)
+
+// WithKeyFunc sets the function used to group similar notifications. Defaults to DefaultKey.
+func WithKeyFunc(key KeyFunc) RateLimitOption {
+	return func(r *RateLimiter) {
+		r.key = key
+	}
+}
+
+type rateLimitBucket struct {
+	windowStart time.Time
+	sent        int
+	suppressed  int
+
+	// lastSuppressed is the latest suppressed report. It's sent with the count of the other suppressed
+	// ones when the bucket expires, if no similar report got through in the meantime.
+	lastSuppressed *spcontext.Report
+	// expiry fires when the window expires, to send the latest suppressed report even if no similar
+	// report comes afterwards.
+	expiry *time.Timer
+}
+
+// pending returns the latest suppressed report with the count of the other suppressed ones attached,
+// and clears them from the bucket.
+func (b *rateLimitBucket) pending() *spcontext.Report {
+	report := withSuppressed(b.lastSuppressed, b.suppressed-1)
+	b.clear()
+	return report
+}
+
+func (b *rateLimitBucket) clear() {
+	b.suppressed = 0
+	b.lastSuppressed = nil
+	if b.expiry != nil {
+		b.expiry.Stop()
+		b.expiry = nil
+	}
+}
+
+// RateLimiter is a Notifier passing on at most a limited number of similar notifications
+// per time window. Suppressed notifications are counted, and the count is attached to the next
+// similar notification which gets through. If none does before the window expires, the latest
+// suppressed notification is sent with the count instead, in the background, ignoring the errors.
+// Flush sends the suppressed notifications right away, so it should be called before the process exits.
+// Errors are still logged by the Context on every occurrence.
+type RateLimiter struct {
+	notifier      spcontext.Notifier
+	limit         int
+	window        time.Duration
+	key           KeyFunc
+	now           func() time.Time
+	suppressedAll atomic.Uint64
+
+	mu        sync.Mutex
+	buckets   map[string]*rateLimitBucket
+	lastSweep time.Time
+}
+
+// NewRateLimiter creates a new Notifier passing on at most limit similar notifications per window.
+func NewRateLimiter(notifier spcontext.Notifier, limit int, window time.Duration, opts ...RateLimitOption) *RateLimiter {
+	r := &RateLimiter{
+		notifier: notifier,
+		limit:    limit,
+		window:   window,
+		key:      DefaultKey,
+		now:      time.Now,
+		buckets:  make(map[string]*rateLimitBucket),
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Notify passes the notification on, unless too many similar ones were sent in the current window.
+func (r *RateLimiter) Notify(err error, rawData ...interface{}) error {
+	return r.NotifyReport(spcontext.ReportFromBugsnag(err, rawData...))
+}
+
+// NotifyReport passes the report on, unless too many similar ones were sent in the current window.
+func (r *RateLimiter) NotifyReport(report *spcontext.Report) error {
+	suppressed, ok, expired := r.allow(r.key(report), report)
+
+	errs := []error{r.sendPending(expired)}
+
+	if !ok {
+		r.suppressedAll.Add(1)
+		return errors.Join(errs...)
+	}
+
+	if err := spcontext.NotifyReport(r.notifier, withSuppressed(report, suppressed)); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// Flush sends the latest suppressed notification of every group of similar ones, with the count
+// of the other suppressed ones attached, without waiting for their windows to expire.
+// It stops early if the context is done.
+func (r *RateLimiter) Flush(ctx context.Context) error {
+	r.mu.Lock()
+	var pending []*spcontext.Report
+	for _, bucket := range r.buckets {
+		if bucket.suppressed > 0 {
+			pending = append(pending, bucket.pending())
+		}
+	}
+	r.mu.Unlock()
+
+	var errs []error
+	for i := range pending {
+		if err := ctx.Err(); err != nil {
+			// The reports which weren't sent are lost, so they stay counted as suppressed.
+			return errors.Join(append(errs, err)...)
+		}
+		errs = append(errs, r.sendPending(pending[i:i+1]))
+	}
+	return errors.Join(errs...)
+}
+
+// sendPending sends the latest suppressed reports of their buckets.
+func (r *RateLimiter) sendPending(reports []*spcontext.Report) error {
+	var errs []error
+	for _, report := range reports {
+		// The report sent is one of the suppressed ones, so it's not counted as suppressed anymore.
+		r.suppressedAll.Add(^uint64(0))
+		if err := spcontext.NotifyReport(r.notifier, report); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// expire sends the latest suppressed report of the bucket once its window expires.
+func (r *RateLimiter) expire(key string, bucket *rateLimitBucket) {
+	r.mu.Lock()
+	// The timer may fire after the bucket was swept, or after a similar report got through.
+	if r.buckets[key] != bucket || bucket.suppressed == 0 || r.now().Sub(bucket.windowStart) < r.window {
+		r.mu.Unlock()
+		return
+	}
+	delete(r.buckets, key)
+	pending := bucket.pending()
+	r.mu.Unlock()
+
+	_ = r.sendPending([]*spcontext.Report{pending})
+}
+
+// withSuppressed returns the report with the count of suppressed similar reports attached, if there were any.
+func withSuppressed(report *spcontext.Report, suppressed int) *spcontext.Report {
+	if suppressed == 0 {
+		return report
+	}
+
+	report = report.Clone()
+	if report.Fields == nil {
+		report.Fields = make(map[string]interface{}, 1)
+	}
+	report.Fields[SuppressedField] = suppressed
+	return report
+}
+
+// AutoNotify notifies about a panic and re-panics.
+// Usage: defer notifier.AutoNotify(ctx)
+func (r *RateLimiter) AutoNotify(rawData ...interface{}) {
+	if recovered := recover(); recovered != nil {
+		notifyPanic(r, recovered, rawData)
+		panic(recovered)
+	}
+}
+
+// Suppressed returns the total number of notifications suppressed by the rate limiter.
+func (r *RateLimiter) Suppressed() uint64 {
+	return r.suppressedAll.Load()
+}
+
+// allow tells whether a report with the given key may be sent, and how many similar
+// reports were suppressed since the last one was sent. It also returns the latest suppressed
+// reports of the expired buckets of other keys, which should be sent now.
+func (r *RateLimiter) allow(key string, report *spcontext.Report) (int, bool, []*spcontext.Report) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	suppressed, ok := r.take(key, report, now)
+
+	return suppressed, ok, r.sweep(now)
+}
+
+func (r *RateLimiter) take(key string, report *spcontext.Report, now time.Time) (int, bool) {
+	bucket, ok := r.buckets[key]
+	if !ok {
+		bucket = &rateLimitBucket{windowStart: now}
+		r.buckets[key] = bucket
+	} else if now.Sub(bucket.windowStart) >= r.window {
+		bucket.windowStart = now
+		bucket.sent = 0
+	}
+
+	if bucket.sent >= r.limit {
+		bucket.suppressed++
+		bucket.lastSuppressed = report
+		if bucket.expiry == nil {
+			bucket.expiry = time.AfterFunc(bucket.windowStart.Add(r.window).Sub(now), func() { r.expire(key, bucket) })
+		}
+		return 0, false
+	}
+
+	bucket.sent++
+	suppressed := bucket.suppressed
+	bucket.clear()
+
+	return suppressed, true
+}
+
+// sweep removes the buckets with expired windows, so that the memory use doesn't grow with every
+// distinct key ever seen. The latest suppressed reports of the removed buckets are returned.
+func (r *RateLimiter) sweep(now time.Time) []*spcontext.Report {
+	if now.Sub(r.lastSweep) < r.window {
+		return nil
+	}
+	r.lastSweep = now
+
+	var expired []*spcontext.Report
+	for key, bucket := range r.buckets {
+		if now.Sub(bucket.windowStart) < r.window {
+			continue
+		}
+		if bucket.suppressed > 0 {
+			expired = append(expired, bucket.pending())
+		}
+		delete(r.buckets, key)
+	}
+	return expired
+}
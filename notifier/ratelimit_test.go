@@ -0,0 +1,142 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/spacelift-io/spcontext"
+	"github.com/spacelift-io/spcontext/testutils"
+)
+
+func TestRateLimiter(t *testing.T) {
+	backend := new(testutils.MockReportNotifier)
+	backend.On("NotifyReport", mock.Anything).Return(nil)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(backend, 2, time.Minute)
+	limiter.now = func() time.Time { return now }
+
+	notify := func(caller string) {
+		assert.NoError(t, limiter.NotifyReport(&spcontext.Report{
+			Error:      errors.New("boom"),
+			ErrorClass: "*errors.errorString",
+			Fields:     map[string]interface{}{"caller": caller},
+		}))
+	}
+
+	fields := func(call int) map[string]interface{} {
+		return backend.Calls[call].Arguments.Get(0).(*spcontext.Report).Fields
+	}
+
+	for i := 0; i < 5; i++ {
+		notify("handler.go:42")
+	}
+	notify("worker.go:7")
+
+	backend.AssertNumberOfCalls(t, "NotifyReport", 3)
+	assert.EqualValues(t, 3, limiter.Suppressed())
+
+	now = now.Add(time.Minute)
+	notify("handler.go:42")
+
+	backend.AssertNumberOfCalls(t, "NotifyReport", 4)
+	assert.Equal(t, 3, fields(3)[SuppressedField])
+	assert.Equal(t, "handler.go:42", fields(3)["caller"])
+
+	now = now.Add(time.Second)
+	notify("handler.go:42")
+
+	assert.NotContains(t, fields(4), SuppressedField)
+}
+
+func TestRateLimiter_FlushesExpiredBuckets(t *testing.T) {
+	backend := new(testutils.MockReportNotifier)
+	backend.On("NotifyReport", mock.Anything).Return(nil)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(backend, 1, time.Minute)
+	limiter.now = func() time.Time { return now }
+
+	notify := func(caller, message string) {
+		assert.NoError(t, limiter.NotifyReport(&spcontext.Report{
+			Error:  errors.New(message),
+			Fields: map[string]interface{}{"caller": caller},
+		}))
+	}
+
+	for i := 1; i <= 4; i++ {
+		notify("handler.go:42", fmt.Sprintf("boom %d", i))
+	}
+	backend.AssertNumberOfCalls(t, "NotifyReport", 1)
+
+	now = now.Add(time.Minute)
+	notify("worker.go:7", "other")
+
+	backend.AssertNumberOfCalls(t, "NotifyReport", 3)
+	flushed := backend.Calls[1].Arguments.Get(0).(*spcontext.Report)
+	assert.EqualError(t, flushed.Error, "boom 4")
+	assert.Equal(t, 2, flushed.Fields[SuppressedField])
+	assert.EqualValues(t, 2, limiter.Suppressed())
+
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	assert.Len(t, limiter.buckets, 1)
+}
+
+func TestRateLimiter_QuietWindow(t *testing.T) {
+	sent := make(chan *spcontext.Report, 2)
+	backend := new(testutils.MockReportNotifier)
+	backend.On("NotifyReport", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		sent <- args.Get(0).(*spcontext.Report)
+	})
+
+	limiter := NewRateLimiter(backend, 1, 50*time.Millisecond)
+
+	for i := 1; i <= 5; i++ {
+		assert.NoError(t, limiter.NotifyReport(&spcontext.Report{
+			Error:  fmt.Errorf("boom %d", i),
+			Fields: map[string]interface{}{"caller": "handler.go:42"},
+		}))
+	}
+	assert.EqualError(t, (<-sent).Error, "boom 1")
+
+	// No similar report comes after the window, so the latest suppressed one is sent when it expires.
+	select {
+	case flushed := <-sent:
+		assert.EqualError(t, flushed.Error, "boom 5")
+		assert.Equal(t, 3, flushed.Fields[SuppressedField])
+		assert.EqualValues(t, 3, limiter.Suppressed())
+	case <-time.After(time.Second):
+		t.Fatal("the suppressed reports were not sent after the window expired")
+	}
+}
+
+func TestRateLimiter_Flush(t *testing.T) {
+	backend := new(testutils.MockReportNotifier)
+	backend.On("NotifyReport", mock.Anything).Return(nil)
+
+	limiter := NewRateLimiter(backend, 1, time.Hour)
+
+	for i := 1; i <= 3; i++ {
+		assert.NoError(t, limiter.NotifyReport(&spcontext.Report{
+			Error:  fmt.Errorf("boom %d", i),
+			Fields: map[string]interface{}{"caller": "handler.go:42"},
+		}))
+	}
+
+	assert.NoError(t, limiter.Flush(context.Background()))
+	backend.AssertNumberOfCalls(t, "NotifyReport", 2)
+	flushed := backend.Calls[1].Arguments.Get(0).(*spcontext.Report)
+	assert.EqualError(t, flushed.Error, "boom 3")
+	assert.Equal(t, 1, flushed.Fields[SuppressedField])
+
+	// Flushed reports aren't sent again.
+	assert.NoError(t, limiter.Flush(context.Background()))
+	backend.AssertNumberOfCalls(t, "NotifyReport", 2)
+}
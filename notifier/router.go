@@ -0,0 +1,52 @@
+package notifier
+
+import (
+	"github.com/spacelift-io/spcontext"
+)
+
+// Route sends the reports matching the matcher to the notifier.
+type Route struct {
+	Match    Matcher
+	Notifier spcontext.Notifier
+}
+
+// router is a Notifier sending reports to the first matching route.
+type router struct {
+	routes   []Route
+	fallback spcontext.Notifier
+}
+
+// Router creates a new Notifier sending each report to the notifier of the
+// first matching route. Reports not matching any route are sent to the fallback
+// notifier, or dropped if it's nil.
+func Router(fallback spcontext.Notifier, routes ...Route) spcontext.Notifier {
+	return &router{routes: routes, fallback: fallback}
+}
+
+// Notify sends the notification to the notifier of the first matching route.
+func (r *router) Notify(err error, rawData ...interface{}) error {
+	return r.NotifyReport(spcontext.ReportFromBugsnag(err, rawData...))
+}
+
+// NotifyReport sends the report to the notifier of the first matching route.
+func (r *router) NotifyReport(report *spcontext.Report) error {
+	for _, route := range r.routes {
+		if route.Match(report) {
+			return spcontext.NotifyReport(route.Notifier, report)
+		}
+	}
+
+	if r.fallback == nil {
+		return nil
+	}
+	return spcontext.NotifyReport(r.fallback, report)
+}
+
+// AutoNotify notifies about a panic and re-panics.
+// Usage: defer notifier.AutoNotify(ctx)
+func (r *router) AutoNotify(rawData ...interface{}) {
+	if recovered := recover(); recovered != nil {
+		notifyPanic(r, recovered, rawData)
+		panic(recovered)
+	}
+}
@@ -0,0 +1,197 @@
+// Package sentry contains a Sentry implementation of a spcontext Notifier.
+package sentry
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	bugsnagerrors "github.com/bugsnag/bugsnag-go/v2/errors"
+	"github.com/getsentry/sentry-go"
+
+	"github.com/spacelift-io/spcontext"
+)
+
+// panicFlushTimeout is how long AutoNotify waits for the panic to be sent before re-panicking.
+const panicFlushTimeout = 2 * time.Second
+
+// Option is used to optionally configure the Notifier on creation.
+type Option func(n *Notifier)
+
+// WithTagFields sets the fields which will be sent as Sentry tags, and not only as extras.
+// Tags are indexed and searchable, so they should be low-cardinality.
+func WithTagFields(keys ...string) Option {
+	return func(n *Notifier) {
+		n.tagFields = append(n.tagFields, keys...)
+	}
+}
+
+// WithInAppPackages sets the package prefixes of the frames considered to be in the application.
+// By default, all the frames outside the standard library are considered in-app.
+func WithInAppPackages(prefixes ...string) Option {
+	return func(n *Notifier) {
+		n.inAppPackages = append(n.inAppPackages, prefixes...)
+	}
+}
+
+// Notifier is a Sentry implementation of a spcontext Notifier. It translates spcontext
+// reports into Sentry events.
+type Notifier struct {
+	client        *sentry.Client
+	tagFields     []string
+	inAppPackages []string
+}
+
+// New creates a new Notifier sending events using the given Sentry client.
+func New(client *sentry.Client, opts ...Option) *Notifier {
+	n := &Notifier{client: client}
+	for _, opt := range opts {
+		opt(n)
+	}
+	return n
+}
+
+// Notify sends the error to Sentry.
+func (n *Notifier) Notify(err error, rawData ...interface{}) error {
+	return n.NotifyReport(spcontext.ReportFromBugsnag(err, rawData...))
+}
+
+// NotifyReport sends the report to Sentry.
+// Events are sent in the background by the Sentry transport, so delivery errors aren't returned.
+// Events dropped on purpose, eg. by sampling or a BeforeSend hook, aren't treated as errors either.
+func (n *Notifier) NotifyReport(report *spcontext.Report) error {
+	n.client.CaptureEvent(n.buildEvent(report), &sentry.EventHint{OriginalException: report.Error}, nil)
+	return nil
+}
+
+// AutoNotify notifies about a panic, waits for it to be sent and re-panics.
+// Usage: defer notifier.AutoNotify(ctx)
+func (n *Notifier) AutoNotify(rawData ...interface{}) {
+	if recovered := recover(); recovered != nil {
+		// We skip the frames of AutoNotify and the panic itself.
+		report := spcontext.ReportFromBugsnag(bugsnagerrors.New(recovered, 2), rawData...)
+		report.Severity = spcontext.SeverityError
+		report.Unhandled = true
+
+		_ = n.NotifyReport(report)
+		n.client.Flush(panicFlushTimeout)
+		panic(recovered)
+	}
+}
+
+// Flush waits until the queued events are sent or the timeout is reached.
+// It returns false if the timeout was reached.
+func (n *Notifier) Flush(timeout time.Duration) bool {
+	return n.client.Flush(timeout)
+}
+
+func (n *Notifier) buildEvent(report *spcontext.Report) *sentry.Event {
+	event := sentry.NewEvent()
+	event.Level = toLevel(report.Severity)
+	event.Transaction = report.Context
+	event.Release = report.AppVersion
+	event.Environment = report.ReleaseStage
+
+	for key, value := range report.UnsectionedFields() {
+		event.Extra[key] = value
+	}
+
+	for section, values := range report.Sections {
+		sentryContext := make(sentry.Context, len(values))
+		for key, value := range values {
+			sentryContext[key] = value
+		}
+		event.Contexts[section] = sentryContext
+	}
+
+	for _, key := range n.tagFields {
+		if value, ok := report.Fields[key]; ok {
+			event.Tags[key] = fmt.Sprint(value)
+		}
+	}
+
+	for key, value := range report.Tags {
+		event.Tags[key] = value
+	}
+
+	if report.User != nil {
+		event.User = sentry.User{ID: report.User.ID, Email: report.User.Email, Name: report.User.Name}
+	}
+
+	exception := sentry.Exception{
+		Type:       report.ErrorClass,
+		Value:      report.Error.Error(),
+		Stacktrace: n.stacktrace(report),
+	}
+
+	if report.Unhandled {
+		handled := false
+		exception.Mechanism = &sentry.Mechanism{Type: "generic", Handled: &handled}
+	}
+
+	event.Exception = []sentry.Exception{exception}
+
+	return event
+}
+
+func (n *Notifier) stacktrace(report *spcontext.Report) *sentry.Stacktrace {
+	if len(report.StackFrames) == 0 {
+		return sentry.ExtractStacktrace(report.Error)
+	}
+
+	// Report frames start with the innermost call, while Sentry expects them to end with it.
+	frames := make([]sentry.Frame, len(report.StackFrames))
+	for i, frame := range report.StackFrames {
+		sentryFrame := sentry.Frame{
+			Function: frame.Function,
+			Module:   frame.Package,
+			Filename: frame.File,
+			AbsPath:  frame.File,
+			Lineno:   frame.LineNumber,
+			InApp:    frame.InProject || n.inApp(frame.Package),
+		}
+
+		for line := frame.LineNumber - len(frame.Code); line <= frame.LineNumber+len(frame.Code); line++ {
+			code, ok := frame.Code[line]
+			switch {
+			case !ok:
+			case line < frame.LineNumber:
+				sentryFrame.PreContext = append(sentryFrame.PreContext, code)
+			case line == frame.LineNumber:
+				sentryFrame.ContextLine = code
+			default:
+				sentryFrame.PostContext = append(sentryFrame.PostContext, code)
+			}
+		}
+
+		frames[len(frames)-1-i] = sentryFrame
+	}
+
+	return &sentry.Stacktrace{Frames: frames}
+}
+
+func (n *Notifier) inApp(pkg string) bool {
+	if len(n.inAppPackages) == 0 {
+		// Standard library packages don't have a domain in their first path element.
+		firstElement, _, _ := strings.Cut(pkg, "/")
+		return strings.Contains(firstElement, ".")
+	}
+
+	for _, prefix := range n.inAppPackages {
+		if strings.HasPrefix(pkg, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func toLevel(severity spcontext.Severity) sentry.Level {
+	switch severity {
+	case spcontext.SeverityWarning:
+		return sentry.LevelWarning
+	case spcontext.SeverityInfo:
+		return sentry.LevelInfo
+	default:
+		return sentry.LevelError
+	}
+}
@@ -0,0 +1,110 @@
+package sentry_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bugsnag/bugsnag-go/v2"
+	"github.com/getsentry/sentry-go"
+	"github.com/go-kit/log"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/spacelift-io/spcontext"
+	spsentry "github.com/spacelift-io/spcontext/notifier/sentry"
+)
+
+type ingestion struct {
+	mu     sync.Mutex
+	events []map[string]interface{}
+}
+
+// ServeHTTP stands in for the Sentry envelope endpoint, collecting the received events.
+func (i *ingestion) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var item map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			continue
+		}
+		if _, ok := item["exception"]; ok {
+			i.mu.Lock()
+			i.events = append(i.events, item)
+			i.mu.Unlock()
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestNotifier(t *testing.T) {
+	ingest := &ingestion{}
+	server := httptest.NewServer(ingest)
+	defer server.Close()
+
+	client, err := sentry.NewClient(sentry.ClientOptions{
+		Dsn:       strings.Replace(server.URL, "http://", "http://public@", 1) + "/1",
+		Transport: sentry.NewHTTPSyncTransport(),
+	})
+	require.NoError(t, err)
+
+	notifier := spsentry.New(client, spsentry.WithTagFields("account"), spsentry.WithInAppPackages("github.com/spacelift-io"))
+	ctx := spcontext.New(log.NewNopLogger(), spcontext.WithNotifier(notifier)).With("account", "acme", "run_id", "01ABC")
+
+	_ = ctx.InternalError(errors.New("boom"), "could not run")
+	require.NoError(t, notifier.Notify(errors.New("careful"), bugsnag.SeverityWarning, bugsnag.User{Id: "user-1"}))
+
+	require.Len(t, ingest.events, 2)
+
+	event := ingest.events[0]
+	assert.Equal(t, "error", event["level"])
+	assert.Equal(t, map[string]interface{}{"account": "acme"}, event["tags"])
+
+	extra := event["extra"].(map[string]interface{})
+	assert.Equal(t, "acme", extra["account"])
+	assert.Equal(t, "01ABC", extra["run_id"])
+	assert.Equal(t, "boom", extra["original_error"])
+
+	exception := event["exception"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "*errors.fundamental", exception["type"])
+	assert.Equal(t, "boom", exception["value"])
+
+	frames := exception["stacktrace"].(map[string]interface{})["frames"].([]interface{})
+	require.NotEmpty(t, frames)
+	innermost := frames[len(frames)-1].(map[string]interface{})
+	assert.Equal(t, "TestNotifier", innermost["function"])
+	assert.Equal(t, true, innermost["in_app"])
+
+	event = ingest.events[1]
+	assert.Equal(t, "warning", event["level"])
+	assert.Equal(t, "user-1", event["user"].(map[string]interface{})["id"])
+}
+
+func TestNotifier_DroppedOnPurpose(t *testing.T) {
+	ingest := &ingestion{}
+	server := httptest.NewServer(ingest)
+	defer server.Close()
+
+	client, err := sentry.NewClient(sentry.ClientOptions{
+		Dsn:        strings.Replace(server.URL, "http://", "http://public@", 1) + "/1",
+		Transport:  sentry.NewHTTPSyncTransport(),
+		BeforeSend: func(*sentry.Event, *sentry.EventHint) *sentry.Event { return nil },
+	})
+	require.NoError(t, err)
+
+	notifier := spsentry.New(client)
+
+	assert.NoError(t, notifier.NotifyReport(&spcontext.Report{Error: errors.New("sampled out")}))
+	assert.Empty(t, ingest.events)
+}
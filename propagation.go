@@ -0,0 +1,125 @@
+package spcontext
+
+import (
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// Carrier holds the trace context propagated across process boundaries, eg. request headers.
+type Carrier interface {
+	Get(key string) string
+	Set(key, value string)
+	Keys() []string
+}
+
+// Propagator is implemented by tracers which can propagate the active span across process boundaries.
+type Propagator interface {
+	// Inject writes the active span into the carrier.
+	Inject(ctx *Context, carrier Carrier)
+	// Extract returns a context holding the span read from the carrier, if any, as the remote parent
+	// of the spans started in it.
+	Extract(ctx *Context, carrier Carrier) *Context
+}
+
+// Inject writes the active span into the carrier, if the tracer of the context is a Propagator,
+// along with the propagating fields.
+func Inject(ctx *Context, carrier Carrier) {
+	if propagator, ok := ctx.Tracer.(Propagator); ok {
+		propagator.Inject(ctx, carrier)
+	}
+	InjectBaggage(ctx, carrier)
+}
+
+// Extract returns a context holding the span read from the carrier as the remote parent of the spans
+// started in it, if the tracer of the context is a Propagator, and the propagating fields.
+func Extract(ctx *Context, carrier Carrier) *Context {
+	if propagator, ok := ctx.Tracer.(Propagator); ok {
+		ctx = propagator.Extract(ctx, carrier)
+	}
+	return ExtractBaggage(ctx, carrier)
+}
+
+// HeaderCarrier is a Carrier backed by HTTP headers.
+type HeaderCarrier http.Header
+
+// Get returns the first value of the header.
+func (c HeaderCarrier) Get(key string) string {
+	return http.Header(c).Get(key)
+}
+
+// Set sets the value of the header.
+func (c HeaderCarrier) Set(key, value string) {
+	http.Header(c).Set(key, value)
+}
+
+// Keys returns the names of the headers.
+func (c HeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// MetadataCarrier is a Carrier backed by gRPC metadata.
+type MetadataCarrier metadata.MD
+
+// Get returns the first value of the key.
+func (c MetadataCarrier) Get(key string) string {
+	if values := metadata.MD(c).Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Set sets the value of the key.
+func (c MetadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+// Keys returns the metadata keys.
+func (c MetadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// MapCarrier is a Carrier backed by a plain map, eg. serialised along with a queued job.
+// Keys are case-insensitive, like in HTTP headers and gRPC metadata.
+type MapCarrier map[string]string
+
+// Get returns the value of the key.
+func (c MapCarrier) Get(key string) string {
+	if value, ok := c[key]; ok {
+		return value
+	}
+	for k, value := range c {
+		if strings.EqualFold(k, key) {
+			return value
+		}
+	}
+	return ""
+}
+
+// Set sets the value of the key, replacing the ones differing only in case.
+func (c MapCarrier) Set(key, value string) {
+	for k := range c {
+		if strings.EqualFold(k, key) {
+			delete(c, k)
+		}
+	}
+	c[key] = value
+}
+
+// Keys returns the keys of the map.
+func (c MapCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
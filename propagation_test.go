@@ -0,0 +1,66 @@
+package spcontext_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/spacelift-io/spcontext"
+)
+
+type propagatingTracer struct {
+	recordingTracer
+}
+
+type remoteParentKey struct{}
+
+func (p *propagatingTracer) Inject(ctx *spcontext.Context, carrier spcontext.Carrier) {
+	carrier.Set("Test-Parent", "parent")
+}
+
+func (p *propagatingTracer) Extract(ctx *spcontext.Context, carrier spcontext.Carrier) *spcontext.Context {
+	return spcontext.WithValue(ctx, remoteParentKey{}, carrier.Get("test-parent"))
+}
+
+func TestPropagation(t *testing.T) {
+	t.Run("carriers", func(t *testing.T) {
+		for name, carrier := range map[string]spcontext.Carrier{
+			"header":   spcontext.HeaderCarrier(http.Header{}),
+			"metadata": spcontext.MetadataCarrier(metadata.MD{}),
+			"map":      spcontext.MapCarrier{},
+		} {
+			t.Run(name, func(t *testing.T) {
+				carrier.Set("Traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+				carrier.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-00f067aa0ba902b7-01")
+
+				assert.Equal(t, "00-0af7651916cd43dd8448eb211c80319c-00f067aa0ba902b7-01", carrier.Get("TRACEPARENT"))
+				assert.Len(t, carrier.Keys(), 1)
+				assert.Empty(t, carrier.Get("tracestate"))
+			})
+		}
+	})
+
+	t.Run("with propagating tracer", func(t *testing.T) {
+		ctx := spcontext.New(log.NewNopLogger(), spcontext.WithTracer(&propagatingTracer{}))
+		header := http.Header{}
+
+		spcontext.Inject(ctx, spcontext.HeaderCarrier(header))
+		extracted := spcontext.Extract(ctx, spcontext.HeaderCarrier(header))
+
+		assert.Equal(t, "parent", header.Get("Test-Parent"))
+		assert.Equal(t, "parent", extracted.Value(remoteParentKey{}))
+	})
+
+	t.Run("with other tracers", func(t *testing.T) {
+		ctx := spcontext.New(log.NewNopLogger())
+		carrier := spcontext.MapCarrier{}
+
+		spcontext.Inject(ctx, carrier)
+
+		assert.Empty(t, carrier)
+		assert.Equal(t, ctx, spcontext.Extract(ctx, carrier))
+	})
+}
@@ -0,0 +1,343 @@
+package spcontext
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bugsnag/bugsnag-go/v2"
+	bugsnagerrors "github.com/bugsnag/bugsnag-go/v2/errors"
+)
+
+// Severity is the severity of a reported error.
+type Severity string
+
+const (
+	// SeverityDefault leaves the choice of severity to the notifier.
+	SeverityDefault Severity = ""
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+// StackFrame is a single frame of the stack trace of a reported error.
+type StackFrame struct {
+	File       string
+	LineNumber int
+	// Function is the name of the function without the package path, eg. "(*Type).Method.func1".
+	Function       string
+	Package        string
+	ProgramCounter uintptr
+	// InProject is set for frames of the project packages.
+	InProject bool
+	// Code holds the source lines around the frame, keyed by their line numbers, if available.
+	Code map[int]string
+}
+
+// User is the user affected by a reported error.
+type User struct {
+	ID    string
+	Name  string
+	Email string
+}
+
+// Report is a backend-neutral error notification.
+type Report struct {
+	// Error is the reported error.
+	Error error
+	// StackFrames is the stack trace of the error, starting with the innermost call.
+	StackFrames []StackFrame
+	// ErrorClass is used by notifiers to group similar errors.
+	ErrorClass string
+	Severity   Severity
+	// Unhandled is set for errors which crash the process, like panics.
+	Unhandled bool
+	Fields    map[string]interface{}
+	// Sections groups related fields, eg. describing the request. Bugsnag shows them as metadata tabs.
+	// The fields routed into sections are kept in Fields too, so that they can be matched.
+	Sections map[string]map[string]interface{}
+	User     *User
+	Tags     map[string]string
+	// Context describes what was happening when the error occurred, eg. a request route.
+	Context      string
+	AppVersion   string
+	ReleaseStage string
+	// Ctx is the context in which the error was reported.
+	Ctx context.Context
+
+	// sectioned holds the keys of the fields routed into sections.
+	sectioned map[string]bool
+}
+
+// Clone returns a copy of the report, which can be modified without affecting the original.
+func (r *Report) Clone() *Report {
+	out := *r
+
+	out.StackFrames = append([]StackFrame(nil), r.StackFrames...)
+
+	if r.Fields != nil {
+		out.Fields = make(map[string]interface{}, len(r.Fields))
+		for key, value := range r.Fields {
+			out.Fields[key] = value
+		}
+	}
+
+	if r.Sections != nil {
+		out.Sections = make(map[string]map[string]interface{}, len(r.Sections))
+		for section, values := range r.Sections {
+			out.Sections[section] = make(map[string]interface{}, len(values))
+			for key, value := range values {
+				out.Sections[section][key] = value
+			}
+		}
+	}
+
+	if r.Tags != nil {
+		out.Tags = make(map[string]string, len(r.Tags))
+		for key, value := range r.Tags {
+			out.Tags[key] = value
+		}
+	}
+
+	if r.User != nil {
+		user := *r.User
+		out.User = &user
+	}
+
+	return &out
+}
+
+// UnsectionedFields returns the fields which weren't routed into any of the sections, for notifiers
+// showing the sections separately.
+func (r *Report) UnsectionedFields() map[string]interface{} {
+	if len(r.sectioned) == 0 {
+		return r.Fields
+	}
+
+	out := make(map[string]interface{}, len(r.Fields))
+	for key, value := range r.Fields {
+		if !r.sectioned[key] {
+			out[key] = value
+		}
+	}
+	return out
+}
+
+// userFields are the keys of the fields describing the user affected by an error.
+type userFields struct {
+	id, name, email string
+}
+
+// WithMetadataTab routes fields into a separate section of the report, shown as a metadata tab in Bugsnag.
+// Fields prefixed with the tab name and a dot, eg. "request.method", are put into the tab with the prefix
+// trimmed. Fields with the given keys are put into the tab as-is.
+func WithMetadataTab(tab string, keys ...string) ContextOption {
+	return func(ctx *Context) {
+		tabs := make(map[string][]string, len(ctx.config.metadataTabs)+1)
+		for name, tabKeys := range ctx.config.metadataTabs {
+			tabs[name] = tabKeys
+		}
+		tabs[tab] = append(tabs[tab][:len(tabs[tab]):len(tabs[tab])], keys...)
+		ctx.config.metadataTabs = tabs
+	}
+}
+
+// WithUserFields sets the keys of the fields describing the user affected by reported errors.
+// Empty keys are ignored.
+func WithUserFields(idKey, nameKey, emailKey string) ContextOption {
+	return func(ctx *Context) {
+		ctx.config.userFields = userFields{id: idKey, name: nameKey, email: emailKey}
+	}
+}
+
+// WithReportContextField sets the key of the field describing what was happening when an error
+// occurred, eg. a request route. It's used as the context of reported errors.
+func WithReportContextField(key string) ContextOption {
+	return func(ctx *Context) {
+		ctx.config.contextField = key
+	}
+}
+
+// WithAppVersion sets the application version attached to reported errors.
+func WithAppVersion(version string) ContextOption {
+	return func(ctx *Context) {
+		ctx.config.appVersion = version
+	}
+}
+
+// WithReleaseStage sets the release stage, eg. "production", attached to reported errors.
+func WithReleaseStage(stage string) ContextOption {
+	return func(ctx *Context) {
+		ctx.config.releaseStage = stage
+	}
+}
+
+// applyTo fills the report using the context configuration.
+// The user and context are read from the fields, which are also routed into sections.
+func (cfg *contextConfig) applyTo(report *Report) {
+	report.AppVersion = cfg.appVersion
+	report.ReleaseStage = cfg.releaseStage
+
+	report.Context = stringField(report.Fields, cfg.contextField)
+
+	user := User{
+		ID:    stringField(report.Fields, cfg.userFields.id),
+		Name:  stringField(report.Fields, cfg.userFields.name),
+		Email: stringField(report.Fields, cfg.userFields.email),
+	}
+	if user != (User{}) {
+		report.User = &user
+	}
+
+	for key, value := range report.Fields {
+		tab, sectionKey, ok := cfg.metadataTab(key)
+		if !ok {
+			continue
+		}
+
+		if report.Sections == nil {
+			report.Sections = make(map[string]map[string]interface{})
+		}
+		if report.Sections[tab] == nil {
+			report.Sections[tab] = make(map[string]interface{})
+		}
+		report.Sections[tab][sectionKey] = value
+
+		if report.sectioned == nil {
+			report.sectioned = make(map[string]bool)
+		}
+		report.sectioned[key] = true
+	}
+}
+
+// metadataTab returns the tab the field with the given key belongs to, and its key within the tab.
+// Fields registered with a tab take precedence over prefixed ones, and the longest matching prefix wins,
+// so that the choice doesn't depend on the order of the tabs.
+func (cfg *contextConfig) metadataTab(key string) (tab, sectionKey string, ok bool) {
+	for name, keys := range cfg.metadataTabs {
+		if slices.Contains(keys, key) && (tab == "" || name < tab) {
+			tab = name
+		}
+	}
+	if tab != "" {
+		return tab, key, true
+	}
+
+	for name := range cfg.metadataTabs {
+		if strings.HasPrefix(key, name+".") && len(name) > len(tab) {
+			tab = name
+		}
+	}
+	if tab == "" {
+		return "", "", false
+	}
+	return tab, strings.TrimPrefix(key, tab+"."), true
+}
+
+func stringField(fields map[string]interface{}, key string) string {
+	if key == "" {
+		return ""
+	}
+	if value, ok := fields[key]; ok {
+		return fmt.Sprint(value)
+	}
+	return ""
+}
+
+// ReportNotifier is implemented by notifiers accepting backend-neutral reports.
+type ReportNotifier interface {
+	NotifyReport(report *Report) error
+}
+
+// NotifyReport sends the report to the notifier. Notifiers implementing ReportNotifier receive
+// the report as-is, the other ones are assumed to be Bugsnag-compatible, and receive the report
+// converted into Bugsnag raw data.
+func NotifyReport(notifier Notifier, report *Report) error {
+	if reportNotifier, ok := notifier.(ReportNotifier); ok {
+		return reportNotifier.NotifyReport(report)
+	}
+
+	err, rawData := BugsnagRawData(report)
+	return notifier.Notify(err, rawData...)
+}
+
+// ReportFromBugsnag converts the arguments of a Bugsnag-compatible Notify call into a report.
+// Notifiers implementing ReportNotifier can use it to implement Notify.
+func ReportFromBugsnag(err error, rawData ...interface{}) *Report {
+	report := &Report{
+		Error:      err,
+		ErrorClass: fmt.Sprintf("%T", err),
+		Fields:     make(map[string]interface{}),
+	}
+
+	switch withFrames := err.(type) {
+	case *errorWithStackFrames:
+		report.Error = withFrames.err
+		report.StackFrames = withFrames.frames
+		report.ErrorClass = fmt.Sprintf("%T", withFrames.err)
+	case bugsnagerrors.ErrorWithStackFrames:
+		for _, frame := range withFrames.StackFrames() {
+			report.StackFrames = append(report.StackFrames, StackFrame{
+				File:           frame.File,
+				LineNumber:     frame.LineNumber,
+				Function:       frame.Name,
+				Package:        frame.Package,
+				ProgramCounter: frame.ProgramCounter,
+			})
+		}
+	}
+
+	for _, datum := range rawData {
+		switch datum := datum.(type) {
+		case bugsnag.ErrorClass:
+			report.ErrorClass = datum.Name
+		case bugsnag.MetaData:
+			for tab, values := range datum {
+				for key, value := range values {
+					if tab == FieldsTab {
+						report.Fields[key] = value
+						continue
+					}
+					if report.Sections == nil {
+						report.Sections = make(map[string]map[string]interface{})
+					}
+					if report.Sections[tab] == nil {
+						report.Sections[tab] = make(map[string]interface{})
+					}
+					report.Sections[tab][key] = value
+				}
+			}
+		case bugsnag.User:
+			report.User = &User{ID: datum.Id, Name: datum.Name, Email: datum.Email}
+		case bugsnag.Context:
+			report.Context = datum.String
+		case bugsnag.Configuration:
+			report.AppVersion = datum.AppVersion
+			report.ReleaseStage = datum.ReleaseStage
+		case bugsnag.HandledState:
+			report.Unhandled = datum.Unhandled
+			report.Severity = fromBugsnagSeverity(datum.OriginalSeverity, report.Severity)
+		case context.Context:
+			report.Ctx = datum
+		default:
+			// Bugsnag severities are of an unexported type, so they can only be compared by value.
+			report.Severity = fromBugsnagSeverity(datum, report.Severity)
+		}
+	}
+
+	return report
+}
+
+func fromBugsnagSeverity(datum interface{}, fallback Severity) Severity {
+	switch datum {
+	case bugsnag.SeverityError:
+		return SeverityError
+	case bugsnag.SeverityWarning:
+		return SeverityWarning
+	case bugsnag.SeverityInfo:
+		return SeverityInfo
+	default:
+		return fallback
+	}
+}
@@ -0,0 +1,159 @@
+package spcontext_test
+
+import (
+	"testing"
+
+	"github.com/bugsnag/bugsnag-go/v2"
+	"github.com/go-kit/log"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/spacelift-io/spcontext"
+	"github.com/spacelift-io/spcontext/testutils"
+)
+
+func TestReportNotifier(t *testing.T) {
+	notifier := new(testutils.MockReportNotifier)
+	notifier.On("NotifyReport", mock.Anything).Return(nil)
+
+	ctx := spcontext.New(log.NewNopLogger(), spcontext.WithNotifier(notifier)).With("account", "acme")
+	problem := errors.New("boom")
+
+	_ = ctx.InternalError(problem, "could not do it")
+
+	notifier.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything)
+	require.Len(t, notifier.Calls, 1)
+
+	report := notifier.Calls[0].Arguments.Get(0).(*spcontext.Report)
+	assert.Equal(t, problem, report.Error)
+	assert.Equal(t, "*errors.fundamental", report.ErrorClass)
+	assert.Equal(t, "acme", report.Fields["account"])
+	assert.Equal(t, "boom", report.Fields["original_error"])
+	assert.Equal(t, spcontext.SeverityDefault, report.Severity)
+	assert.NotEmpty(t, report.StackFrames)
+	assert.Equal(t, "TestReportNotifier", report.StackFrames[0].Function)
+}
+
+func TestBugsnagRawData(t *testing.T) {
+	original := &spcontext.Report{
+		Error:       errors.New("boom"),
+		StackFrames: []spcontext.StackFrame{{File: "main.go", LineNumber: 42, Function: "main", Package: "main"}},
+		ErrorClass:  "*errors.fundamental",
+		Severity:    spcontext.SeverityWarning,
+		Fields:      map[string]interface{}{"account": "acme"},
+		User:        &spcontext.User{ID: "user-1"},
+		Context:     "GET /stacks",
+	}
+
+	err, rawData := spcontext.BugsnagRawData(original)
+
+	assert.EqualError(t, err, "boom")
+	assert.Contains(t, rawData, bugsnag.MetaData{spcontext.FieldsTab: {"account": "acme"}})
+	assert.Contains(t, rawData, bugsnag.ErrorClass{Name: "*errors.fundamental"})
+	assert.Contains(t, rawData, bugsnag.User{Id: "user-1"})
+	assert.Contains(t, rawData, bugsnag.Context{String: "GET /stacks"})
+	assert.Contains(t, rawData, bugsnag.SeverityWarning)
+
+	assert.Equal(t, original, spcontext.ReportFromBugsnag(err, rawData...))
+}
+
+func TestReportConfiguration(t *testing.T) {
+	notifier := new(testutils.MockNotifier)
+	notifier.On("Notify", mock.Anything, mock.Anything).Return(nil)
+
+	ctx := spcontext.New(
+		log.NewNopLogger(),
+		spcontext.WithNotifier(notifier),
+		spcontext.WithMetadataTab("request", "request_id"),
+		spcontext.WithUserFields("user.id", "", "user.email"),
+		spcontext.WithReportContextField("request.route"),
+		spcontext.WithAppVersion("1.2.3"),
+		spcontext.WithReleaseStage("production"),
+	).With(
+		"request.method", "GET",
+		"request.route", "/stacks/{id}",
+		"request_id", "req-1",
+		"user.id", "user-1",
+		"user.email", "user@example.com",
+		"account", "acme",
+	)
+
+	_ = ctx.InternalError(errors.New("boom"), "could not do it")
+
+	rawData := notifier.Calls[0].Arguments.Get(1).([]interface{})
+
+	metaData := rawData[0].(bugsnag.MetaData)
+	assert.Equal(t, map[string]interface{}{
+		"method":     "GET",
+		"route":      "/stacks/{id}",
+		"request_id": "req-1",
+	}, metaData["request"])
+	assert.Equal(t, "acme", metaData[spcontext.FieldsTab]["account"])
+	assert.NotContains(t, metaData[spcontext.FieldsTab], "request.method")
+	assert.Equal(t, "user-1", metaData[spcontext.FieldsTab]["user.id"])
+
+	assert.Contains(t, rawData, bugsnag.User{Id: "user-1", Email: "user@example.com"})
+	assert.Contains(t, rawData, bugsnag.Context{String: "/stacks/{id}"})
+	assert.Contains(t, rawData, bugsnag.Configuration{AppVersion: "1.2.3", ReleaseStage: "production"})
+}
+
+func TestReportConfiguration_OverlappingTabs(t *testing.T) {
+	notifier := new(testutils.MockReportNotifier)
+	notifier.On("NotifyReport", mock.Anything).Return(nil)
+
+	ctx := spcontext.New(
+		log.NewNopLogger(),
+		spcontext.WithNotifier(notifier),
+		spcontext.WithMetadataTab("request"),
+		spcontext.WithMetadataTab("request.headers"),
+	).With("request.method", "GET", "request.headers.host", "app.spacelift.io")
+
+	// Tabs are kept in a map, so a few attempts make a random choice very likely to show up.
+	for i := 0; i < 20; i++ {
+		_ = ctx.InternalError(errors.New("boom"), "could not do it")
+
+		report := notifier.Calls[i].Arguments.Get(0).(*spcontext.Report)
+		assert.Equal(t, map[string]map[string]interface{}{
+			"request":         {"method": "GET"},
+			"request.headers": {"host": "app.spacelift.io"},
+		}, report.Sections)
+		assert.Equal(t, "GET", report.Fields["request.method"], "routed fields should still be matchable")
+		assert.NotContains(t, report.UnsectionedFields(), "request.method")
+	}
+}
+
+type genericRepository[T any] struct{}
+
+func (genericRepository[T]) find() error {
+	return func() error {
+		return errors.New("not found")
+	}()
+}
+
+func TestReportStackFrames(t *testing.T) {
+	notifier := new(testutils.MockReportNotifier)
+	notifier.On("NotifyReport", mock.Anything).Return(nil)
+
+	ctx := spcontext.New(
+		log.NewNopLogger(),
+		spcontext.WithNotifier(notifier),
+		spcontext.WithProjectPackages("github.com/spacelift-io/spcontext"),
+		spcontext.WithSourceContext(1),
+	)
+
+	_ = ctx.InternalError(genericRepository[string]{}.find(), "could not find")
+
+	frames := notifier.Calls[0].Arguments.Get(0).(*spcontext.Report).StackFrames
+	require.Len(t, frames, 3, "library frames of the test runner should be trimmed")
+
+	assert.Equal(t, "github.com/spacelift-io/spcontext_test", frames[0].Package)
+	assert.Equal(t, "genericRepository[...].find.func1", frames[0].Function)
+	assert.True(t, frames[0].InProject)
+	assert.Len(t, frames[0].Code, 3)
+	assert.Contains(t, frames[0].Code[frames[0].LineNumber], `errors.New("not found")`)
+
+	assert.Equal(t, "genericRepository[...].find", frames[1].Function)
+	assert.Equal(t, "TestReportStackFrames", frames[2].Function)
+}
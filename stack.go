@@ -0,0 +1,212 @@
+package spcontext
+
+import (
+	"bufio"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// WithProjectPackages sets the prefixes of the packages which belong to the project. Frames of these packages
+// are marked as in-project in reported errors, and the outermost frames of other packages are trimmed.
+func WithProjectPackages(prefixes ...string) ContextOption {
+	return func(ctx *Context) {
+		ctx.config.projectPackages = append(ctx.config.projectPackages[:len(ctx.config.projectPackages):len(ctx.config.projectPackages)], prefixes...)
+	}
+}
+
+// WithSourceContext attaches the given number of source lines around each in-project frame of reported errors,
+// if the sources are available where the program runs.
+func WithSourceContext(lines int) ContextOption {
+	return func(ctx *Context) {
+		ctx.config.sourceContextLines = lines
+	}
+}
+
+type stackTracer interface {
+	error
+	StackTrace() errors.StackTrace
+}
+
+// findStackTracer returns the deepest error carrying a stack trace in the error tree.
+// Multi-errors are walked depth-first, so the first branch with a stack trace wins.
+func findStackTracer(err error) stackTracer {
+	if err == nil {
+		return nil
+	}
+
+	var children []error
+	switch x := err.(type) {
+	case *batchError:
+		// Batches are reported with the stack of the call site, not the one of any single item.
+		return nil
+	case interface{ Unwrap() []error }:
+		children = x.Unwrap()
+	case interface{ Unwrap() error }:
+		children = []error{x.Unwrap()}
+	}
+
+	for _, child := range children {
+		if st := findStackTracer(child); st != nil {
+			return st
+		}
+	}
+
+	st, _ := err.(stackTracer)
+	return st
+}
+
+// spcontextPackage is the import path of this package, used to trim its frames from reported stacks.
+var spcontextPackage = reflect.TypeOf(Context{}).PkgPath()
+
+// callerStack is a stackTracer for errors which don't carry a stack trace of their own.
+type callerStack struct {
+	error
+	stack errors.StackTrace
+}
+
+// StackTrace returns the stack captured when the error was reported.
+func (s *callerStack) StackTrace() errors.StackTrace { return s.stack }
+
+// Unwrap returns initial error, provides compatibility for Go 1.13 error chains.
+func (s *callerStack) Unwrap() error { return s.error }
+
+// captureStack captures the current call stack for the given error.
+// The frames belonging to this package are trimmed when the stack is converted into report frames.
+func captureStack(err error) stackTracer {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(2, pcs)
+	pcs = pcs[:n]
+
+	stack := make(errors.StackTrace, len(pcs))
+	for i, pc := range pcs {
+		stack[i] = errors.Frame(pc)
+	}
+
+	return &callerStack{error: err, stack: stack}
+}
+
+// stackFrames converts the stack trace of a github.com/pkg/errors error, processing it according to
+// the configuration. Runtime and spcontext frames are always trimmed.
+func (cfg *contextConfig) stackFrames(st stackTracer) []StackFrame {
+	stackTrace := st.StackTrace()
+
+	pcs := make([]uintptr, len(stackTrace))
+	for i, frame := range stackTrace {
+		pcs[i] = uintptr(frame)
+	}
+
+	var out []StackFrame
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+
+		if frame.Function != "" {
+			pkg, fnName := splitFunctionName(frame.Function)
+			if pkg != "runtime" && pkg != spcontextPackage {
+				out = append(out, StackFrame{
+					File:           frame.File,
+					LineNumber:     frame.Line,
+					Function:       fnName,
+					Package:        pkg,
+					ProgramCounter: frame.PC,
+					InProject:      cfg.isProjectPackage(pkg),
+				})
+			}
+		}
+
+		if !more {
+			break
+		}
+	}
+
+	if len(cfg.projectPackages) > 0 {
+		// The outermost library frames, like the ones of an HTTP server or the test runner, are just noise.
+		last := len(out) - 1
+		for last >= 0 && !out[last].InProject {
+			last--
+		}
+		if last >= 0 {
+			out = out[:last+1]
+		}
+	}
+
+	if cfg.sourceContextLines > 0 {
+		sources := make(map[string][]string)
+		for i := range out {
+			if out[i].InProject || len(cfg.projectPackages) == 0 {
+				out[i].Code = sourceContext(sources, out[i].File, out[i].LineNumber, cfg.sourceContextLines)
+			}
+		}
+	}
+
+	return out
+}
+
+func (cfg *contextConfig) isProjectPackage(pkg string) bool {
+	for _, prefix := range cfg.projectPackages {
+		if strings.HasPrefix(pkg, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitFunctionName splits a fully qualified function name into the package path and the function name,
+// eg. "github.com/org/repo/pkg.(*Type[...]).Method.func1" into "github.com/org/repo/pkg" and "(*Type[...]).Method.func1".
+func splitFunctionName(name string) (string, string) {
+	// Type arguments may contain dots and slashes, so the package path can only be found before them.
+	prefix := name
+	if i := strings.IndexByte(prefix, '['); i != -1 {
+		prefix = prefix[:i]
+	}
+
+	// Dots in the last element of the package path are escaped, so the first dot after the last slash
+	// separates the package path from the function name.
+	lastSlash := strings.LastIndexByte(prefix, '/')
+	dot := strings.IndexByte(prefix[lastSlash+1:], '.')
+	if dot == -1 {
+		return "", name
+	}
+	dot += lastSlash + 1
+
+	return strings.ReplaceAll(name[:dot], "%2e", "."), name[dot+1:]
+}
+
+// sourceContext returns the source lines around the given line, keyed by their line numbers.
+// Files are read at most once, using the given cache.
+func sourceContext(cache map[string][]string, file string, line, contextLines int) map[int]string {
+	lines, ok := cache[file]
+	if !ok {
+		lines = readLines(file)
+		cache[file] = lines
+	}
+
+	if line < 1 || line > len(lines) {
+		return nil
+	}
+
+	out := make(map[int]string)
+	for i := max(1, line-contextLines); i <= min(len(lines), line+contextLines); i++ {
+		out[i] = lines[i-1]
+	}
+	return out
+}
+
+func readLines(file string) []string {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines
+}
@@ -0,0 +1,31 @@
+package spcontext
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitFunctionName(t *testing.T) {
+	testCases := []struct {
+		name, pkg, function string
+	}{
+		{name: "main.main", pkg: "main", function: "main"},
+		{name: "net/http.HandlerFunc.ServeHTTP", pkg: "net/http", function: "HandlerFunc.ServeHTTP"},
+		{name: "github.com/org/repo/pkg.(*Type).Method", pkg: "github.com/org/repo/pkg", function: "(*Type).Method"},
+		{name: "github.com/org/repo/pkg.Func.func1.2", pkg: "github.com/org/repo/pkg", function: "Func.func1.2"},
+		{name: "github.com/org/repo/pkg.Map[...]", pkg: "github.com/org/repo/pkg", function: "Map[...]"},
+		{name: "github.com/org/repo/pkg.(*Cache[go.shape.string]).Get.func1", pkg: "github.com/org/repo/pkg", function: "(*Cache[go.shape.string]).Get.func1"},
+		{name: "gopkg.in/yaml%2ev3.Marshal", pkg: "gopkg.in/yaml.v3", function: "Marshal"},
+		{name: "nopackage", pkg: "", function: "nopackage"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pkg, function := splitFunctionName(tc.name)
+
+			assert.Equal(t, tc.pkg, pkg)
+			assert.Equal(t, tc.function, function)
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package spcontext
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ProblemContentType is the content type of RFC 7807 problem details responses.
+const ProblemContentType = "application/problem+json"
+
+// internalErrorMessage is the safe message used when no safe message is available.
+const internalErrorMessage = "internal error"
+
+// codedError is a safe error carrying a status code.
+type codedError struct {
+	code codes.Code
+	err  error
+}
+
+func (e *codedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *codedError) Unwrap() error {
+	return e.err
+}
+
+// SafeErrorf creates a safe, user-friendly error message carrying the given status code.
+// The code is used when converting the reported error into API responses.
+func SafeErrorf(code codes.Code, format string, args ...interface{}) SafeMessage {
+	return &codedError{code: code, err: fmt.Errorf(format, args...)}
+}
+
+// CodeOf returns the status code of the error.
+// Errors without an explicit code are treated as internal errors, except for context cancellation
+// and deadline errors, which keep their own codes even if they were reported.
+func CodeOf(err error) codes.Code {
+	if err == nil {
+		return codes.OK
+	}
+
+	var notifiedErr notifiedError
+	if errors.As(err, &notifiedErr) {
+		var coded *codedError
+		if errors.As(notifiedErr.safe, &coded) {
+			return coded.code
+		}
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return codes.DeadlineExceeded
+	default:
+		return codes.Internal
+	}
+}
+
+// safeMessage returns the message which is safe to show to users.
+func safeMessage(err error) string {
+	if message, ok := SafeMessageOf(err); ok {
+		return message
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return context.Canceled.Error()
+	case errors.Is(err, context.DeadlineExceeded):
+		return context.DeadlineExceeded.Error()
+	default:
+		return internalErrorMessage
+	}
+}
+
+// GRPCStatus converts the error into a gRPC status. Only the safe message is exposed,
+// unreported errors are converted into a generic internal error.
+func GRPCStatus(err error) *status.Status {
+	if err == nil {
+		return status.New(codes.OK, "")
+	}
+	return status.New(CodeOf(err), safeMessage(err))
+}
+
+// HTTPStatusFromCode maps the gRPC status code onto a HTTP status code.
+func HTTPStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499 // Client Closed Request, there is no constant for it in net/http.
+	case codes.InvalidArgument, codes.OutOfRange, codes.FailedPrecondition:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Problem is an RFC 7807 problem details object.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+}
+
+// ProblemFromError converts the error into a problem details object.
+// Only the safe message is exposed, unreported errors are converted into a generic internal error.
+func ProblemFromError(err error) *Problem {
+	code := HTTPStatusFromCode(CodeOf(err))
+
+	title := http.StatusText(code)
+	if title == "" {
+		title = CodeOf(err).String()
+	}
+
+	return &Problem{
+		Type:   "about:blank",
+		Title:  title,
+		Status: code,
+		Detail: safeMessage(err),
+	}
+}
+
+// WriteProblem writes the error as an RFC 7807 application/problem+json response.
+func WriteProblem(w http.ResponseWriter, r *http.Request, err error) {
+	problem := ProblemFromError(err)
+	if r != nil && r.URL != nil {
+		problem.Instance = r.URL.Path
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(problem.Status)
+	_ = json.NewEncoder(w).Encode(problem)
+}
@@ -0,0 +1,109 @@
+package spcontext_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+
+	"github.com/spacelift-io/spcontext"
+)
+
+func TestGRPCStatus(t *testing.T) {
+	ctx := spcontext.New(log.NewNopLogger())
+
+	testCases := []struct {
+		name    string
+		err     error
+		code    codes.Code
+		message string
+	}{
+		{
+			name:    "coded safe error",
+			err:     ctx.Error(errors.New("sql: no rows"), errors.New("could not find stack"), spcontext.SafeErrorf(codes.NotFound, "stack %s not found", "abc")),
+			code:    codes.NotFound,
+			message: "stack abc not found",
+		},
+		{
+			name:    "wrapped coded safe error",
+			err:     fmt.Errorf("handler: %w", ctx.Error(errors.New("bad"), errors.New("invalid"), spcontext.SafeErrorf(codes.InvalidArgument, "invalid name"))),
+			code:    codes.InvalidArgument,
+			message: "invalid name",
+		},
+		{
+			name:    "safe error without code",
+			err:     ctx.InternalError(errors.New("boom"), "could not do it"),
+			code:    codes.Internal,
+			message: "internal error",
+		},
+		{
+			name:    "unreported error",
+			err:     errors.New("secret database details"),
+			code:    codes.Internal,
+			message: "internal error",
+		},
+		{
+			name:    "context canceled",
+			err:     context.Canceled,
+			code:    codes.Canceled,
+			message: "context canceled",
+		},
+		{
+			name:    "reported deadline error",
+			err:     ctx.InternalError(fmt.Errorf("calling the runner: %w", context.DeadlineExceeded), "could not schedule the run"),
+			code:    codes.DeadlineExceeded,
+			message: "internal error",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			st := spcontext.GRPCStatus(tc.err)
+
+			assert.Equal(t, tc.code, st.Code())
+			assert.Equal(t, tc.message, st.Message())
+		})
+	}
+
+	t.Run("nil error", func(t *testing.T) {
+		assert.Equal(t, codes.OK, spcontext.GRPCStatus(nil).Code())
+	})
+}
+
+func TestHTTPStatusFromCode(t *testing.T) {
+	assert.Equal(t, http.StatusOK, spcontext.HTTPStatusFromCode(codes.OK))
+	assert.Equal(t, http.StatusBadRequest, spcontext.HTTPStatusFromCode(codes.FailedPrecondition))
+	assert.Equal(t, http.StatusGatewayTimeout, spcontext.HTTPStatusFromCode(codes.DeadlineExceeded))
+	assert.Equal(t, http.StatusInternalServerError, spcontext.HTTPStatusFromCode(codes.DataLoss))
+}
+
+func TestWriteProblem(t *testing.T) {
+	ctx := spcontext.New(log.NewNopLogger())
+	err := ctx.Error(errors.New("sql: no rows"), errors.New("could not find stack"), spcontext.SafeErrorf(codes.NotFound, "stack not found"))
+
+	recorder := httptest.NewRecorder()
+	spcontext.WriteProblem(recorder, httptest.NewRequest(http.MethodGet, "/stacks/abc", nil), err)
+
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.Equal(t, spcontext.ProblemContentType, recorder.Header().Get("Content-Type"))
+
+	var problem spcontext.Problem
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &problem))
+
+	assert.Equal(t, spcontext.Problem{
+		Type:     "about:blank",
+		Title:    "Not Found",
+		Status:   http.StatusNotFound,
+		Detail:   "stack not found",
+		Instance: "/stacks/abc",
+	}, problem)
+	assert.NotContains(t, recorder.Body.String(), "sql: no rows")
+}
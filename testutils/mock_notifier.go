@@ -1,6 +1,10 @@
 package testutils
 
-import "github.com/stretchr/testify/mock"
+import (
+	"github.com/stretchr/testify/mock"
+
+	"github.com/spacelift-io/spcontext"
+)
 
 // MockNotifier is a mock implementation of Notifier.
 type MockNotifier struct {
@@ -16,3 +20,23 @@ func (m *MockNotifier) Notify(err error, extras ...interface{}) error {
 func (m *MockNotifier) AutoNotify(extras ...interface{}) {
 	m.Called(extras)
 }
+
+// MockReportNotifier is a mock implementation of a Notifier accepting backend-neutral reports.
+type MockReportNotifier struct {
+	mock.Mock
+}
+
+// Notify is a mock implementation of the the real thing.
+func (m *MockReportNotifier) Notify(err error, extras ...interface{}) error {
+	return m.Called(err, extras).Error(0)
+}
+
+// AutoNotify is a mock implementation of the the real thing.
+func (m *MockReportNotifier) AutoNotify(extras ...interface{}) {
+	m.Called(extras)
+}
+
+// NotifyReport is a mock implementation of the the real thing.
+func (m *MockReportNotifier) NotifyReport(report *spcontext.Report) error {
+	return m.Called(report).Error(0)
+}
@@ -1,20 +1,45 @@
 package spcontext
 
 import (
-	"errors"
 	"fmt"
 	"runtime"
 	"strings"
+	"time"
 	"unicode"
 )
 
+// SpanKind describes the relationship of a span to its parent and children, so that backends
+// can render service maps.
+type SpanKind string
+
+const (
+	// SpanKindUnspecified leaves the choice of kind to the tracer.
+	SpanKindUnspecified SpanKind = ""
+	SpanKindInternal    SpanKind = "internal"
+	SpanKindServer      SpanKind = "server"
+	SpanKindClient      SpanKind = "client"
+	SpanKindProducer    SpanKind = "producer"
+	SpanKindConsumer    SpanKind = "consumer"
+)
+
 // SpanConfig configures Span creation.
 type SpanConfig struct {
 	Tags      *Fields
 	Operation string
 	Resource  string
+	Kind      SpanKind
+	// StartTime is the time the span started at, the current time if zero.
+	StartTime time.Time
+	// Links are references to related spans, eg. the one which enqueued the job handled by the new span.
+	Links []SpanRef
+	// NewRoot makes the new span the root of a new trace, instead of a child of the current span.
+	NewRoot bool
 }
 
+// SpanRef is a serialisable reference to a span, which can be passed between processes, eg. along with
+// a queued job. It holds the identifiers of the span for each of the tracers, keyed by the tracers.
+type SpanRef map[string]string
+
 // SpanOption is used to modify the SpanConfig.
 type SpanOption func(*SpanConfig)
 
@@ -50,9 +75,42 @@ func WithResource(resource string, a ...interface{}) SpanOption {
 	}
 }
 
+// WithSpanKind sets the kind of the Span.
+func WithSpanKind(kind SpanKind) SpanOption {
+	return func(cfg *SpanConfig) {
+		cfg.Kind = kind
+	}
+}
+
+// WithStartTime sets the time the Span started at, eg. for work measured elsewhere.
+func WithStartTime(start time.Time) SpanOption {
+	return func(cfg *SpanConfig) {
+		cfg.StartTime = start
+	}
+}
+
+// WithLinks links the Span to the referenced spans.
+func WithLinks(refs ...SpanRef) SpanOption {
+	return func(cfg *SpanConfig) {
+		cfg.Links = append(cfg.Links, refs...)
+	}
+}
+
+// WithNewRoot starts the Span as the root of a new trace, instead of a child of the current span.
+// Use with WithLinks to relate it to the span which caused it, eg. when consuming queued jobs.
+func WithNewRoot() SpanOption {
+	return func(cfg *SpanConfig) {
+		cfg.NewRoot = true
+	}
+}
+
 // SpanCloseConfig configures Span finalization.
 type SpanCloseConfig struct {
 	Drop, Analyze bool
+	// EndTime is the time the span ended at, the current time if zero.
+	EndTime time.Time
+	// Resource replaces the resource name the span was started with, if not empty.
+	Resource string
 }
 
 // SpanCloseOption is used to modify the SpanCloseConfig.
@@ -72,6 +130,23 @@ func WithDrop() SpanCloseOption {
 	}
 }
 
+// WithEndTime sets the time the Span ended at, eg. for work measured elsewhere.
+func WithEndTime(end time.Time) SpanCloseOption {
+	return func(cfg *SpanCloseConfig) {
+		cfg.EndTime = end
+	}
+}
+
+// WithCloseResource replaces the resource name of the Span, eg. with a route only known once
+// the request was served.
+func WithCloseResource(resource string, a ...interface{}) SpanCloseOption {
+	resource = fmt.Sprintf(resource, a...)
+
+	return func(cfg *SpanCloseConfig) {
+		cfg.Resource = resource
+	}
+}
+
 // Tracer is used to create spans.
 type Tracer interface {
 	OnSpanStart(ctx *Context, name, resource string) *Context
@@ -79,12 +154,36 @@ type Tracer interface {
 	GetLogFields(ctx *Context) []interface{}
 }
 
+// ConfigurableTracer is implemented by tracers which support the whole SpanConfig and SpanCloseConfig,
+// such as span kinds, links and explicit timestamps. Other tracers only get the names of the spans and
+// whether to drop or analyze them.
+type ConfigurableTracer interface {
+	OnSpanStartWithConfig(ctx *Context, cfg SpanConfig) *Context
+	OnSpanCloseWithConfig(ctx *Context, err error, fields []interface{}, cfg SpanCloseConfig)
+}
+
+// EventTracer is implemented by tracers which can record span events.
+type EventTracer interface {
+	OnSpanEvent(ctx *Context, name string, timestamp time.Time, fields []interface{})
+}
+
+// SpanReferencer is implemented by tracers which can reference their spans, so that other spans can be
+// linked to them.
+type SpanReferencer interface {
+	GetSpanRef(ctx *Context) SpanRef
+}
+
 // Span is a single tracing span, which can be closed with the given error.
 type Span interface {
 	Analyze()
 	Close(err error, opts ...SpanCloseOption)
+	// CloseWith closes the span with the error the pointer refers to when it's called,
+	// so that it can be deferred in functions with a named error result: defer span.CloseWith(&err).
+	CloseWith(errp *error, opts ...SpanCloseOption)
 	Drop()
 	SetTags(tags ...interface{})
+	// AddEvent records a timestamped event inside the span, eg. a phase of a long operation.
+	AddEvent(name string, kvs ...interface{})
 }
 
 type activeSpanContextKey struct{}
@@ -92,7 +191,12 @@ type activeSpanContextKey struct{}
 // StartSpan starts a new span using the context fields as metadata.
 // It returns a new context with attached trace and span IDs as metadata.
 func (ctx *Context) StartSpan(opts ...SpanOption) (*Context, Span) {
-	pc, _, _, _ := runtime.Caller(1)
+	return ctx.startSpan(2, opts...)
+}
+
+// startSpan starts a new span, named after the function the given number of frames up the stack by default.
+func (ctx *Context) startSpan(skip int, opts ...SpanOption) (*Context, *span) {
+	pc, _, _, _ := runtime.Caller(skip)
 	funcName := runtime.FuncForPC(pc).Name()
 	if i := strings.LastIndex(funcName, "/"); i != -1 {
 		funcName = funcName[i:]
@@ -106,7 +210,12 @@ func (ctx *Context) StartSpan(opts ...SpanOption) (*Context, Span) {
 		opt(&cfg)
 	}
 
-	newCtx := ctx.Tracer.OnSpanStart(ctx, cfg.Operation, cfg.Resource)
+	var newCtx *Context
+	if tracer, ok := ctx.Tracer.(ConfigurableTracer); ok {
+		newCtx = tracer.OnSpanStartWithConfig(ctx, cfg)
+	} else {
+		newCtx = ctx.Tracer.OnSpanStart(ctx, cfg.Operation, cfg.Resource)
+	}
 	activeSpan := &span{ctx: newCtx, fields: cfg.Tags}
 
 	ctx.onStartSpan(activeSpan)
@@ -114,6 +223,32 @@ func (ctx *Context) StartSpan(opts ...SpanOption) (*Context, Span) {
 	return WithValue(newCtx, activeSpanContextKey{}, activeSpan), activeSpan
 }
 
+// Trace runs the function in a new span, closing it with the returned error.
+// If the function panics, the span is closed with the recovered panic, which is then re-raised.
+func Trace(ctx *Context, opts []SpanOption, fn func(ctx *Context) error) (err error) {
+	spanCtx, span := ctx.startSpan(2, opts...)
+	defer span.closeTraced(&err)
+
+	return fn(spanCtx)
+}
+
+// TraceValue is like Trace, for functions which also return a value.
+func TraceValue[T any](ctx *Context, opts []SpanOption, fn func(ctx *Context) (T, error)) (value T, err error) {
+	spanCtx, span := ctx.startSpan(2, opts...)
+	defer span.closeTraced(&err)
+
+	return fn(spanCtx)
+}
+
+// SpanRef returns a reference to the active span, which can be used to link other spans to it.
+// It's nil if there's no active span, or the tracer can't reference its spans.
+func (ctx *Context) SpanRef() SpanRef {
+	if tracer, ok := ctx.Tracer.(SpanReferencer); ok {
+		return tracer.GetSpanRef(ctx)
+	}
+	return nil
+}
+
 func (ctx *Context) onStartSpan(activeSpan *span) {
 	if len(ctx.onSpanStartHooks) == 0 {
 		return
@@ -138,21 +273,40 @@ func (s *span) Analyze() {
 }
 
 func (s *span) Close(err error, opts ...SpanCloseOption) {
-	cfg := SpanCloseConfig{}
+	cfg := SpanCloseConfig{Drop: s.drop, Analyze: s.analyze}
 	for _, opt := range opts {
 		opt(&cfg)
 	}
 
-	fields := s.fields.EvaluateFields()
+	fields := append(s.fields.EvaluateFields(), cancellationFields(s.ctx)...)
+	fields = append(fields, ErrorFields(err)...)
 
 	// If the error was wrapped with a user-facing and internal error, make sure it's the internal
 	// error that we report to our observability.
-	var notifiedErr notifiedError
-	if errors.As(err, &notifiedErr) {
-		err = notifiedErr.internal
+	err = Internal(err)
+
+	if tracer, ok := s.ctx.Tracer.(ConfigurableTracer); ok {
+		tracer.OnSpanCloseWithConfig(s.ctx, err, fields, cfg)
+	} else {
+		s.ctx.Tracer.OnSpanClose(s.ctx, err, fields, cfg.Drop, cfg.Analyze)
+	}
+}
+
+func (s *span) CloseWith(errp *error, opts ...SpanCloseOption) {
+	var err error
+	if errp != nil {
+		err = *errp
 	}
+	s.Close(err, opts...)
+}
 
-	s.ctx.Tracer.OnSpanClose(s.ctx, err, fields, s.drop || cfg.Drop, s.analyze || cfg.Analyze)
+// closeTraced must be deferred directly, so that it can recover panics of the traced function.
+func (s *span) closeTraced(errp *error) {
+	if recovered := recover(); recovered != nil {
+		s.Close(fmt.Errorf("panic: %v", recovered))
+		panic(recovered)
+	}
+	s.CloseWith(errp)
 }
 
 func (s *span) Drop() {
@@ -163,6 +317,12 @@ func (s *span) SetTags(tags ...interface{}) {
 	s.fields = s.fields.With(tags...)
 }
 
+func (s *span) AddEvent(name string, kvs ...interface{}) {
+	if tracer, ok := s.ctx.Tracer.(EventTracer); ok {
+		tracer.OnSpanEvent(s.ctx, name, time.Now(), (&Fields{}).With(kvs...).EvaluateFields())
+	}
+}
+
 func (s *span) Value(key string) interface{} {
 	return s.fields.Value(key)
 }
@@ -1,41 +1,100 @@
 package datadog
 
 import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/DataDog/dd-trace-go/v2/ddtrace/ext"
 	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
 
 	"github.com/spacelift-io/spcontext"
-	"github.com/spacelift-io/spcontext/tracing/internal"
+	"github.com/spacelift-io/spcontext/internal"
+)
+
+// Keys of the span identifiers in span references.
+const (
+	traceIDRefKey = "dd.trace_id"
+	spanIDRefKey  = "dd.span_id"
 )
 
+// causesTag is the span tag holding the messages of all the causes of a multi-error.
+const causesTag = "error.causes"
+
+type remoteParentContextKey struct{}
+
 // Tracer is an Datadog implementation of a Tracer.
 type Tracer struct {
 }
 
 // OnSpanStart is called when a new span is created.
 func (t *Tracer) OnSpanStart(ctx *spcontext.Context, name, resource string) *spcontext.Context {
+	return t.OnSpanStartWithConfig(ctx, spcontext.SpanConfig{Operation: name, Resource: resource})
+}
+
+// OnSpanStartWithConfig is called when a new span is created.
+func (t *Tracer) OnSpanStartWithConfig(ctx *spcontext.Context, cfg spcontext.SpanConfig) *spcontext.Context {
 	opts := []tracer.StartSpanOption{tracer.Measured()}
-	if resource != "" {
-		opts = append(opts, tracer.ResourceName(resource))
+	if cfg.Resource != "" {
+		opts = append(opts, tracer.ResourceName(cfg.Resource))
+	}
+	if cfg.Kind != spcontext.SpanKindUnspecified {
+		// Datadog span kinds have the same names as ours.
+		opts = append(opts, tracer.Tag(ext.SpanKind, string(cfg.Kind)))
+	}
+	if !cfg.StartTime.IsZero() {
+		opts = append(opts, tracer.StartTime(cfg.StartTime))
 	}
 
-	_, newCtx := tracer.StartSpanFromContext(ctx, name, opts...)
+	var links []tracer.SpanLink
+	for _, ref := range cfg.Links {
+		if link, ok := spanLinkFromRef(ref); ok {
+			links = append(links, link)
+		}
+	}
+	if len(links) > 0 {
+		opts = append(opts, tracer.WithSpanLinks(links))
+	}
+
+	if _, ok := tracer.SpanFromContext(ctx); !ok && !cfg.NewRoot {
+		if remoteParent, ok := ctx.Value(remoteParentContextKey{}).(*tracer.SpanContext); ok {
+			opts = append(opts, tracer.ChildOf(remoteParent))
+		}
+	}
+
+	if cfg.NewRoot {
+		// Spans started without a parent are the roots of new traces.
+		span := tracer.StartSpan(cfg.Operation, opts...)
+		return spcontext.FromStdContext(tracer.ContextWithSpan(ctx, span))
+	}
+
+	_, newCtx := tracer.StartSpanFromContext(ctx, cfg.Operation, opts...)
 	return spcontext.FromStdContext(newCtx)
 }
 
 // OnSpanClose is called when a span is closed.
 func (t *Tracer) OnSpanClose(ctx *spcontext.Context, err error, fields []interface{}, drop, analyze bool) {
+	t.OnSpanCloseWithConfig(ctx, err, fields, spcontext.SpanCloseConfig{Drop: drop, Analyze: analyze})
+}
+
+// OnSpanCloseWithConfig is called when a span is closed.
+func (t *Tracer) OnSpanCloseWithConfig(ctx *spcontext.Context, err error, fields []interface{}, cfg spcontext.SpanCloseConfig) {
 	span, ok := tracer.SpanFromContext(ctx)
 	if !ok {
 		ctx.Warnf("No span in context.")
 		return
 	}
 
-	if drop {
+	if cfg.Drop {
 		span.SetTag(ext.ManualDrop, true)
 	}
 
-	if analyze || (err != nil && !drop) {
+	if cfg.Resource != "" {
+		span.SetTag(ext.ResourceName, cfg.Resource)
+	}
+
+	if cfg.Analyze || (err != nil && !cfg.Drop) {
 		span.SetTag(ext.AnalyticsEvent, true)
 	}
 
@@ -45,7 +104,37 @@ func (t *Tracer) OnSpanClose(ctx *spcontext.Context, err error, fields []interfa
 		span.SetTag(key, value)
 	}
 
-	span.Finish(tracer.WithError(internal.UnwrapError(err)))
+	// Datadog spans only hold a single error, so the messages of all the causes of
+	// multi-errors are recorded in a separate tag.
+	causes := internal.UnwrapErrors(err)
+	if len(causes) > 1 {
+		messages := make([]string, len(causes))
+		for i, cause := range causes {
+			messages[i] = cause.Error()
+		}
+		span.SetTag(causesTag, strings.Join(messages, "\n"))
+	}
+
+	opts := []tracer.FinishOption{tracer.WithError(internal.UnwrapError(err))}
+	if !cfg.EndTime.IsZero() {
+		opts = append(opts, tracer.FinishTime(cfg.EndTime))
+	}
+
+	span.Finish(opts...)
+}
+
+// OnSpanEvent is called when an event is added to a span.
+func (t *Tracer) OnSpanEvent(ctx *spcontext.Context, name string, timestamp time.Time, fields []interface{}) {
+	span, ok := tracer.SpanFromContext(ctx)
+	if !ok {
+		ctx.Warnf("No span in context.")
+		return
+	}
+
+	span.AddEvent(name,
+		tracer.WithSpanEventTimestamp(timestamp),
+		tracer.WithSpanEventAttributes(internal.DeduplicateFields(fields)),
+	)
 }
 
 // GetLogFields returns the fields which should be used in a log message in this context.
@@ -59,3 +148,88 @@ func (t *Tracer) GetLogFields(ctx *spcontext.Context) []interface{} {
 		"dd.span_id", span.Context().SpanID(),
 	}
 }
+
+// Inject writes the active span into the carrier, using the propagation styles of the Datadog tracer.
+func (t *Tracer) Inject(ctx *spcontext.Context, carrier spcontext.Carrier) {
+	span, ok := tracer.SpanFromContext(ctx)
+	if !ok {
+		return
+	}
+
+	if err := tracer.Inject(span.Context(), textMapCarrier{carrier}); err != nil {
+		_ = ctx.DirectError(err, "failed to inject Datadog trace context")
+	}
+}
+
+// Extract reads the remote span from the carrier, using the propagation styles of the Datadog tracer.
+func (t *Tracer) Extract(ctx *spcontext.Context, carrier spcontext.Carrier) *spcontext.Context {
+	remoteParent, err := tracer.Extract(textMapCarrier{carrier})
+	if err != nil {
+		if !errors.Is(err, tracer.ErrSpanContextNotFound) {
+			_ = ctx.DirectError(err, "failed to extract Datadog trace context")
+		}
+		return ctx
+	}
+
+	return spcontext.WithValue(ctx, remoteParentContextKey{}, remoteParent)
+}
+
+// GetSpanRef returns a reference to the active span.
+func (t *Tracer) GetSpanRef(ctx *spcontext.Context) spcontext.SpanRef {
+	span, ok := tracer.SpanFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	return spcontext.SpanRef{
+		traceIDRefKey: span.Context().TraceID(),
+		spanIDRefKey:  strconv.FormatUint(span.Context().SpanID(), 10),
+	}
+}
+
+// spanLinkFromRef converts the span reference, holding the 128-bit trace ID as hex
+// and the span ID as decimal, into a span link.
+func spanLinkFromRef(ref spcontext.SpanRef) (tracer.SpanLink, bool) {
+	traceID := ref[traceIDRefKey]
+	if traceID == "" || len(traceID) > 32 {
+		return tracer.SpanLink{}, false
+	}
+
+	var link tracer.SpanLink
+	if len(traceID) > 16 {
+		high, err := strconv.ParseUint(traceID[:len(traceID)-16], 16, 64)
+		if err != nil {
+			return tracer.SpanLink{}, false
+		}
+		link.TraceIDHigh = high
+		traceID = traceID[len(traceID)-16:]
+	}
+
+	low, err := strconv.ParseUint(traceID, 16, 64)
+	if err != nil {
+		return tracer.SpanLink{}, false
+	}
+	link.TraceID = low
+
+	spanID, err := strconv.ParseUint(ref[spanIDRefKey], 10, 64)
+	if err != nil {
+		return tracer.SpanLink{}, false
+	}
+	link.SpanID = spanID
+
+	return link, true
+}
+
+// textMapCarrier adapts the carrier to the Datadog TextMapReader and TextMapWriter interfaces.
+type textMapCarrier struct {
+	spcontext.Carrier
+}
+
+// ForeachKey calls the handler for each key and its value, until it returns an error.
+func (c textMapCarrier) ForeachKey(handler func(key, val string) error) error {
+	for _, key := range c.Keys() {
+		if err := handler(key, c.Get(key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package datadog
+
+import (
+	"testing"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/mocktracer"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/spacelift-io/spcontext"
+)
+
+func TestSpanLinkFromRef(t *testing.T) {
+	testCases := []struct {
+		name string
+		ref  spcontext.SpanRef
+		link tracer.SpanLink
+		ok   bool
+	}{
+		{
+			name: "128-bit trace ID",
+			ref:  spcontext.SpanRef{traceIDRefKey: "5b8efff798038103d269b633813fc60c", spanIDRefKey: "12345"},
+			link: tracer.SpanLink{TraceIDHigh: 0x5b8efff798038103, TraceID: 0xd269b633813fc60c, SpanID: 12345},
+			ok:   true,
+		},
+		{
+			name: "64-bit trace ID",
+			ref:  spcontext.SpanRef{traceIDRefKey: "d269b633813fc60c", spanIDRefKey: "12345"},
+			link: tracer.SpanLink{TraceID: 0xd269b633813fc60c, SpanID: 12345},
+			ok:   true,
+		},
+		{
+			name: "short trace ID",
+			ref:  spcontext.SpanRef{traceIDRefKey: "4d2", spanIDRefKey: "1"},
+			link: tracer.SpanLink{TraceID: 1234, SpanID: 1},
+			ok:   true,
+		},
+		{
+			name: "missing trace ID",
+			ref:  spcontext.SpanRef{spanIDRefKey: "12345"},
+		},
+		{
+			name: "reference of another tracer",
+			ref:  spcontext.SpanRef{"otel.trace_id": "5b8efff798038103d269b633813fc60c", "otel.span_id": "d269b633813fc60c"},
+		},
+		{
+			name: "trace ID too long",
+			ref:  spcontext.SpanRef{traceIDRefKey: "15b8efff798038103d269b633813fc60c", spanIDRefKey: "12345"},
+		},
+		{
+			name: "malformed low trace ID",
+			ref:  spcontext.SpanRef{traceIDRefKey: "5b8efff798038103d269b633813fc6zz", spanIDRefKey: "12345"},
+		},
+		{
+			name: "malformed high trace ID",
+			ref:  spcontext.SpanRef{traceIDRefKey: "zz8efff798038103d269b633813fc60c", spanIDRefKey: "12345"},
+		},
+		{
+			name: "hex span ID",
+			ref:  spcontext.SpanRef{traceIDRefKey: "d269b633813fc60c", spanIDRefKey: "d269b633813fc60c"},
+		},
+		{
+			name: "missing span ID",
+			ref:  spcontext.SpanRef{traceIDRefKey: "d269b633813fc60c"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			link, ok := spanLinkFromRef(tc.ref)
+
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.link, link)
+		})
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	ctx := spcontext.New(log.NewNopLogger(), spcontext.WithTracer(&Tracer{}))
+
+	producerCtx, producer := ctx.StartSpan(spcontext.WithOperation("producer"))
+	carrier := spcontext.MapCarrier{}
+	spcontext.Inject(producerCtx, carrier)
+	producer.Close(nil)
+
+	_, consumer := spcontext.Extract(ctx, carrier).StartSpan(spcontext.WithOperation("consumer"))
+	consumer.Close(nil)
+
+	spans := mt.FinishedSpans()
+	require.Len(t, spans, 2)
+	assert.Equal(t, spans[0].TraceID(), spans[1].TraceID())
+	assert.Equal(t, spans[0].SpanID(), spans[1].ParentID())
+}
@@ -1,6 +1,10 @@
 package multitracer
 
-import "github.com/spacelift-io/spcontext"
+import (
+	"time"
+
+	"github.com/spacelift-io/spcontext"
+)
 
 // multitracer is a Tracer which calls all the given tracers.
 type multitracer []spcontext.Tracer
@@ -12,8 +16,18 @@ func New(tracers ...spcontext.Tracer) spcontext.Tracer {
 
 // OnSpanStart is called when a new span is created.
 func (t multitracer) OnSpanStart(ctx *spcontext.Context, name, resource string) *spcontext.Context {
+	return t.OnSpanStartWithConfig(ctx, spcontext.SpanConfig{Operation: name, Resource: resource})
+}
+
+// OnSpanStartWithConfig is called when a new span is created. Tracers which aren't configurable
+// only get the names of the span.
+func (t multitracer) OnSpanStartWithConfig(ctx *spcontext.Context, cfg spcontext.SpanConfig) *spcontext.Context {
 	for _, tracer := range t {
-		ctx = tracer.OnSpanStart(ctx, name, resource)
+		if configurable, ok := tracer.(spcontext.ConfigurableTracer); ok {
+			ctx = configurable.OnSpanStartWithConfig(ctx, cfg)
+		} else {
+			ctx = tracer.OnSpanStart(ctx, cfg.Operation, cfg.Resource)
+		}
 	}
 
 	return ctx
@@ -21,8 +35,28 @@ func (t multitracer) OnSpanStart(ctx *spcontext.Context, name, resource string)
 
 // OnSpanClose is called when a span is closed.
 func (t multitracer) OnSpanClose(ctx *spcontext.Context, err error, fields []any, drop, analyze bool) {
+	t.OnSpanCloseWithConfig(ctx, err, fields, spcontext.SpanCloseConfig{Drop: drop, Analyze: analyze})
+}
+
+// OnSpanCloseWithConfig is called when a span is closed. Tracers which aren't configurable
+// only get whether to drop or analyze the span.
+func (t multitracer) OnSpanCloseWithConfig(ctx *spcontext.Context, err error, fields []any, cfg spcontext.SpanCloseConfig) {
 	for _, tracer := range t {
-		tracer.OnSpanClose(ctx, err, fields, drop, analyze)
+		if configurable, ok := tracer.(spcontext.ConfigurableTracer); ok {
+			configurable.OnSpanCloseWithConfig(ctx, err, fields, cfg)
+		} else {
+			tracer.OnSpanClose(ctx, err, fields, cfg.Drop, cfg.Analyze)
+		}
+	}
+}
+
+// OnSpanEvent is called when an event is added to a span, for all the tracers which
+// can record events.
+func (t multitracer) OnSpanEvent(ctx *spcontext.Context, name string, timestamp time.Time, fields []any) {
+	for _, tracer := range t {
+		if eventTracer, ok := tracer.(spcontext.EventTracer); ok {
+			eventTracer.OnSpanEvent(ctx, name, timestamp, fields)
+		}
 	}
 }
 
@@ -37,3 +71,45 @@ func (t multitracer) GetLogFields(ctx *spcontext.Context) []any {
 
 	return fields
 }
+
+// GetSpanRef returns a reference to the active span, holding its identifiers
+// for all the tracers which can reference their spans.
+func (t multitracer) GetSpanRef(ctx *spcontext.Context) spcontext.SpanRef {
+	var ref spcontext.SpanRef
+
+	for _, tracer := range t {
+		referencer, ok := tracer.(spcontext.SpanReferencer)
+		if !ok {
+			continue
+		}
+
+		for key, value := range referencer.GetSpanRef(ctx) {
+			if ref == nil {
+				ref = make(spcontext.SpanRef)
+			}
+			ref[key] = value
+		}
+	}
+
+	return ref
+}
+
+// Inject writes the active span of all the tracers which are propagators into the carrier.
+func (t multitracer) Inject(ctx *spcontext.Context, carrier spcontext.Carrier) {
+	for _, tracer := range t {
+		if propagator, ok := tracer.(spcontext.Propagator); ok {
+			propagator.Inject(ctx, carrier)
+		}
+	}
+}
+
+// Extract reads the remote span of all the tracers which are propagators from the carrier.
+func (t multitracer) Extract(ctx *spcontext.Context, carrier spcontext.Carrier) *spcontext.Context {
+	for _, tracer := range t {
+		if propagator, ok := tracer.(spcontext.Propagator); ok {
+			ctx = propagator.Extract(ctx, carrier)
+		}
+	}
+
+	return ctx
+}
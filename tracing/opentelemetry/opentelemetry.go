@@ -2,21 +2,47 @@ package opentelemetry
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/spacelift-io/spcontext"
-	"github.com/spacelift-io/spcontext/tracing/internal"
+	"github.com/spacelift-io/spcontext/internal"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/trace"
 )
 
+// Keys of the span identifiers in span references.
+const (
+	traceIDRefKey = "otel.trace_id"
+	spanIDRefKey  = "otel.span_id"
+)
+
+var spanKinds = map[spcontext.SpanKind]trace.SpanKind{
+	spcontext.SpanKindInternal: trace.SpanKindInternal,
+	spcontext.SpanKindServer:   trace.SpanKindServer,
+	spcontext.SpanKindClient:   trace.SpanKindClient,
+	spcontext.SpanKindProducer: trace.SpanKindProducer,
+	spcontext.SpanKindConsumer: trace.SpanKindConsumer,
+}
+
 // Tracer is an OpenTelemetry implementation of a Tracer.
 type Tracer struct {
+	// Propagator propagates the spans across process boundaries, eg. propagation.TraceContext{}
+	// for the W3C traceparent and tracestate headers. The global propagator is used if nil.
+	Propagator propagation.TextMapPropagator
 }
 
 // OnSpanStart is called when a new span is created.
 func (t *Tracer) OnSpanStart(ctx *spcontext.Context, name, resource string) *spcontext.Context {
+	return t.OnSpanStartWithConfig(ctx, spcontext.SpanConfig{Operation: name, Resource: resource})
+}
+
+// OnSpanStartWithConfig is called when a new span is created.
+func (t *Tracer) OnSpanStartWithConfig(ctx *spcontext.Context, cfg spcontext.SpanConfig) *spcontext.Context {
+	name, resource := cfg.Operation, cfg.Resource
+
 	var opts []trace.SpanStartOption
 	if resource != "" {
 		opts = append(opts, trace.WithAttributes(attribute.String("resource", resource)))
@@ -25,6 +51,26 @@ func (t *Tracer) OnSpanStart(ctx *spcontext.Context, name, resource string) *spc
 		opts = append(opts, trace.WithAttributes(attribute.String("operation.name", name)))
 	}
 
+	if kind, ok := spanKinds[cfg.Kind]; ok {
+		opts = append(opts, trace.WithSpanKind(kind))
+	}
+	if !cfg.StartTime.IsZero() {
+		opts = append(opts, trace.WithTimestamp(cfg.StartTime))
+	}
+
+	var links []trace.Link
+	for _, ref := range cfg.Links {
+		if spanCtx, ok := spanContextFromRef(ref); ok {
+			links = append(links, trace.Link{SpanContext: spanCtx})
+		}
+	}
+	if len(links) > 0 {
+		opts = append(opts, trace.WithLinks(links...))
+	}
+	if cfg.NewRoot {
+		opts = append(opts, trace.WithNewRoot())
+	}
+
 	parentContext := ctx
 
 	existingParent := trace.SpanFromContext(ctx)
@@ -39,6 +85,11 @@ func (t *Tracer) OnSpanStart(ctx *spcontext.Context, name, resource string) *spc
 
 // OnSpanClose is called when a span is closed.
 func (t *Tracer) OnSpanClose(ctx *spcontext.Context, err error, fields []any, drop, analyze bool) {
+	t.OnSpanCloseWithConfig(ctx, err, fields, spcontext.SpanCloseConfig{Drop: drop, Analyze: analyze})
+}
+
+// OnSpanCloseWithConfig is called when a span is closed.
+func (t *Tracer) OnSpanCloseWithConfig(ctx *spcontext.Context, err error, fields []any, cfg spcontext.SpanCloseConfig) {
 	span := trace.SpanFromContext(ctx)
 	if span == nil || !span.SpanContext().IsValid() {
 		ctx.Warnf("No span in context.")
@@ -48,16 +99,43 @@ func (t *Tracer) OnSpanClose(ctx *spcontext.Context, err error, fields []any, dr
 	// Currently we don't have a way to drop a span in OpenTelemetry.
 	// The main issue is that even if we drop a parent span, the child spans will still be recorded.
 
+	if cfg.Resource != "" {
+		span.SetAttributes(attribute.String("resource", cfg.Resource))
+	}
+
 	for key, value := range internal.DeduplicateFields(fields) {
 		span.SetAttributes(attribute.String(key, fmt.Sprintf("%v", value)))
 	}
 
 	if err != nil {
-		span.RecordError(internal.UnwrapError(err))
+		for _, cause := range internal.UnwrapErrors(err) {
+			span.RecordError(cause)
+		}
 		span.SetStatus(codes.Error, "")
 	}
 
-	span.End(trace.WithStackTrace(err != nil))
+	opts := []trace.SpanEndOption{trace.WithStackTrace(err != nil)}
+	if !cfg.EndTime.IsZero() {
+		opts = append(opts, trace.WithTimestamp(cfg.EndTime))
+	}
+
+	span.End(opts...)
+}
+
+// OnSpanEvent is called when an event is added to a span.
+func (t *Tracer) OnSpanEvent(ctx *spcontext.Context, name string, timestamp time.Time, fields []any) {
+	span := trace.SpanFromContext(ctx)
+	if span == nil || !span.SpanContext().IsValid() {
+		ctx.Warnf("No span in context.")
+		return
+	}
+
+	var attributes []attribute.KeyValue
+	for key, value := range internal.DeduplicateFields(fields) {
+		attributes = append(attributes, attribute.String(key, fmt.Sprintf("%v", value)))
+	}
+
+	span.AddEvent(name, trace.WithTimestamp(timestamp), trace.WithAttributes(attributes...))
 }
 
 // GetLogFields returns the fields which should be used in a log message in this context.
@@ -74,3 +152,53 @@ func (t *Tracer) GetLogFields(ctx *spcontext.Context) []any {
 		"otel.span_id", spanCtx.SpanID(),
 	}
 }
+
+// Inject writes the active span into the carrier, using the propagator of the tracer.
+func (t *Tracer) Inject(ctx *spcontext.Context, carrier spcontext.Carrier) {
+	t.propagator().Inject(ctx, carrier)
+}
+
+// Extract reads the remote span from the carrier, using the propagator of the tracer.
+func (t *Tracer) Extract(ctx *spcontext.Context, carrier spcontext.Carrier) *spcontext.Context {
+	return spcontext.FromStdContext(t.propagator().Extract(ctx, carrier))
+}
+
+func (t *Tracer) propagator() propagation.TextMapPropagator {
+	if t.Propagator != nil {
+		return t.Propagator
+	}
+	return otel.GetTextMapPropagator()
+}
+
+// GetSpanRef returns a reference to the active span.
+func (t *Tracer) GetSpanRef(ctx *spcontext.Context) spcontext.SpanRef {
+	span := trace.SpanFromContext(ctx)
+	if span == nil || !span.SpanContext().IsValid() {
+		return nil
+	}
+
+	spanCtx := span.SpanContext()
+
+	return spcontext.SpanRef{
+		traceIDRefKey: spanCtx.TraceID().String(),
+		spanIDRefKey:  spanCtx.SpanID().String(),
+	}
+}
+
+func spanContextFromRef(ref spcontext.SpanRef) (trace.SpanContext, bool) {
+	traceID, err := trace.TraceIDFromHex(ref[traceIDRefKey])
+	if err != nil {
+		return trace.SpanContext{}, false
+	}
+
+	spanID, err := trace.SpanIDFromHex(ref[spanIDRefKey])
+	if err != nil {
+		return trace.SpanContext{}, false
+	}
+
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+		Remote:  true,
+	}), true
+}
@@ -0,0 +1,46 @@
+package opentelemetry_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/spacelift-io/spcontext"
+	"github.com/spacelift-io/spcontext/tracing/opentelemetry"
+)
+
+func TestPropagation(t *testing.T) {
+	const traceID = "0af7651916cd43dd8448eb211c80319c"
+
+	propagate := func(t *testing.T, tracer *opentelemetry.Tracer) {
+		ctx := spcontext.New(log.NewNopLogger(), spcontext.WithTracer(tracer))
+		incoming := http.Header{"Traceparent": []string{"00-" + traceID + "-b7ad6b7169203331-01"}}
+
+		ctx = spcontext.Extract(ctx, spcontext.HeaderCarrier(incoming))
+		ctx, span := ctx.StartSpan()
+		defer span.Close(nil)
+
+		outgoing := http.Header{}
+		spcontext.Inject(ctx, spcontext.HeaderCarrier(outgoing))
+
+		// Without an SDK configured, spans are non-recording and keep the context of their remote parent.
+		assert.Equal(t, "00-"+traceID+"-b7ad6b7169203331-01", outgoing.Get("Traceparent"))
+		assert.Equal(t, traceID, ctx.SpanRef()["otel.trace_id"])
+	}
+
+	t.Run("with the propagator of the tracer", func(t *testing.T) {
+		propagate(t, &opentelemetry.Tracer{Propagator: propagation.TraceContext{}})
+	})
+
+	t.Run("with the global propagator", func(t *testing.T) {
+		global := otel.GetTextMapPropagator()
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+		defer otel.SetTextMapPropagator(global)
+
+		propagate(t, &opentelemetry.Tracer{})
+	})
+}
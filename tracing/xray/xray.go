@@ -1,27 +1,57 @@
 package xray
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"net/url"
 	"reflect"
+	"sync"
+	"time"
 
+	"github.com/aws/aws-xray-sdk-go/header"
 	"github.com/aws/aws-xray-sdk-go/xray"
 
 	"github.com/spacelift-io/spcontext"
-	"github.com/spacelift-io/spcontext/tracing/internal"
+	"github.com/spacelift-io/spcontext/internal"
 )
 
+// Keys of the segment identifiers in span references.
+const (
+	traceIDRefKey   = "xray.trace_id"
+	segmentIDRefKey = "xray.segment_id"
+)
+
+// eventsNamespace is the namespace of the segment metadata holding span events.
+const eventsNamespace = "events"
+
+type remoteParentContextKey struct{}
+
 // Tracer is an AWS X-Ray implementation of a Tracer.
 type Tracer struct {
 }
 
 // OnSpanStart is called when a new span is created.
 func (t *Tracer) OnSpanStart(ctx *spcontext.Context, name, resource string) *spcontext.Context {
+	return t.OnSpanStartWithConfig(ctx, spcontext.SpanConfig{Operation: name, Resource: resource})
+}
+
+// OnSpanStartWithConfig is called when a new span is created.
+func (t *Tracer) OnSpanStartWithConfig(ctx *spcontext.Context, cfg spcontext.SpanConfig) *spcontext.Context {
+	name, resource := cfg.Operation, cfg.Resource
 	createFn := xray.BeginSubsegment
 
 	// Depending on whether the segment exists or not, we either create a
 	// subsegment or a new segment.
-	if xray.GetSegment(ctx) == nil {
+	if xray.GetSegment(ctx) == nil || cfg.NewRoot {
 		createFn = xray.BeginSegment
+
+		if remoteParent, ok := ctx.Value(remoteParentContextKey{}).(*header.Header); ok && !cfg.NewRoot {
+			createFn = func(ctx context.Context, name string) (context.Context, *xray.Segment) {
+				// The request is only used for sampling decisions, if the remote parent didn't make one.
+				return xray.NewSegmentFromHeader(ctx, name, &http.Request{URL: &url.URL{Path: name}}, remoteParent)
+			}
+		}
 	}
 
 	newCtx, segment := createFn(ctx, name)
@@ -37,18 +67,57 @@ func (t *Tracer) OnSpanStart(ctx *spcontext.Context, name, resource string) *spc
 		}
 	}
 
+	if cfg.Kind != spcontext.SpanKindUnspecified {
+		if err := segment.AddAnnotation("kind", string(cfg.Kind)); err != nil {
+			_ = ctx.DirectError(err, "failed to add kind annotation to an X-Ray segment")
+		}
+	}
+
+	setKindAndStartTime(segment, cfg.Kind, cfg.StartTime)
+
+	// X-Ray segments can't be linked, so we record the linked segments in the metadata.
+	var links []map[string]string
+	for _, ref := range cfg.Links {
+		if ref[traceIDRefKey] != "" {
+			links = append(links, map[string]string{
+				"trace_id":   ref[traceIDRefKey],
+				"segment_id": ref[segmentIDRefKey],
+			})
+		}
+	}
+	if len(links) > 0 {
+		if err := segment.AddMetadata("links", links); err != nil {
+			_ = ctx.DirectError(err, "failed to add links metadata to an X-Ray segment")
+		}
+	}
+
 	return spcontext.FromStdContext(newCtx)
 }
 
 // OnSpanClose is called when a span is closed.
 func (t *Tracer) OnSpanClose(ctx *spcontext.Context, err error, fields []any, drop, analyze bool) {
+	t.OnSpanCloseWithConfig(ctx, err, fields, spcontext.SpanCloseConfig{Drop: drop, Analyze: analyze})
+}
+
+// OnSpanCloseWithConfig is called when a span is closed.
+func (t *Tracer) OnSpanCloseWithConfig(ctx *spcontext.Context, err error, fields []any, cfg spcontext.SpanCloseConfig) {
 	segment := xray.GetSegment(ctx)
 	if segment == nil {
 		ctx.Warnf("No segment in context.")
 		return
 	}
 
-	setDropAndAnalyze(segment, drop, analyze)
+	setDropAndAnalyze(segment, cfg.Drop, cfg.Analyze)
+
+	if cfg.Resource != "" {
+		if err := segment.AddAnnotation("resource", cfg.Resource); err != nil {
+			_ = ctx.DirectError(err, "failed to add resource annotation to an X-Ray segment")
+		}
+	}
+
+	if !cfg.EndTime.IsZero() {
+		setEndTime(segment, cfg.EndTime)
+	}
 
 	for key, value := range internal.DeduplicateFields(fields) {
 		if err := segment.AddAnnotation(key, toXRayAnnotationValue(value)); err != nil {
@@ -56,7 +125,38 @@ func (t *Tracer) OnSpanClose(ctx *spcontext.Context, err error, fields []any, dr
 		}
 	}
 
-	segment.Close(internal.UnwrapError(err))
+	// X-Ray segments can hold multiple exceptions, so we record all the causes of
+	// multi-errors, closing the segment with the last one.
+	causes := internal.UnwrapErrors(err)
+	for len(causes) > 1 {
+		if err := segment.AddError(causes[0]); err != nil {
+			_ = ctx.DirectError(err, "failed to add error to an X-Ray segment")
+		}
+		causes = causes[1:]
+	}
+
+	var cause error
+	if len(causes) == 1 {
+		cause = causes[0]
+	}
+
+	segment.Close(cause)
+}
+
+// OnSpanEvent is called when an event is added to a span.
+// X-Ray segments have no events, so they're recorded in the metadata of the segment, under the events
+// namespace, as lists of occurrences keyed by the event names.
+func (t *Tracer) OnSpanEvent(ctx *spcontext.Context, name string, timestamp time.Time, fields []any) {
+	segment := xray.GetSegment(ctx)
+	if segment == nil {
+		ctx.Warnf("No segment in context.")
+		return
+	}
+
+	addEventMetadata(segment, name, map[string]any{
+		"timestamp": timestamp.Format(time.RFC3339Nano),
+		"fields":    internal.DeduplicateFields(fields),
+	})
 }
 
 // GetLogFields returns the fields which should be used in a log message in this context.
@@ -75,6 +175,42 @@ func (t *Tracer) GetLogFields(ctx *spcontext.Context) []any {
 	}
 }
 
+// Inject writes the active segment into the carrier as the X-Amzn-Trace-Id header.
+func (t *Tracer) Inject(ctx *spcontext.Context, carrier spcontext.Carrier) {
+	segment := xray.GetSegment(ctx)
+	if segment == nil {
+		return
+	}
+
+	carrier.Set(xray.TraceIDHeaderKey, segment.DownstreamHeader().String())
+}
+
+// Extract reads the remote segment from the X-Amzn-Trace-Id header in the carrier.
+func (t *Tracer) Extract(ctx *spcontext.Context, carrier spcontext.Carrier) *spcontext.Context {
+	remoteParent := header.FromString(carrier.Get(xray.TraceIDHeaderKey))
+	if remoteParent.TraceID == "" {
+		return ctx
+	}
+
+	return spcontext.WithValue(ctx, remoteParentContextKey{}, remoteParent)
+}
+
+// GetSpanRef returns a reference to the active segment.
+func (t *Tracer) GetSpanRef(ctx *spcontext.Context) spcontext.SpanRef {
+	segment := xray.GetSegment(ctx)
+	if segment == nil {
+		return nil
+	}
+
+	segment.RLock()
+	defer segment.RUnlock()
+
+	return spcontext.SpanRef{
+		traceIDRefKey:   segment.TraceID,
+		segmentIDRefKey: segment.ID,
+	}
+}
+
 // Not 100% sure about that. The docs aren't entirely clear on this and
 // there are no references to these fields in the docs, but they are public so
 // presumably they are meant to be used?
@@ -89,6 +225,97 @@ func setDropAndAnalyze(segment *xray.Segment, drop, analyze bool) {
 	}
 }
 
+// Calls to remote services are recorded in subsegments of the remote namespace, which
+// X-Ray uses to render them in the service map.
+func setKindAndStartTime(segment *xray.Segment, kind spcontext.SpanKind, start time.Time) {
+	segment.Lock()
+	defer segment.Unlock()
+
+	if segment.ParentSegment != segment && (kind == spcontext.SpanKindClient || kind == spcontext.SpanKindProducer) {
+		segment.Namespace = "remote"
+	}
+
+	if !start.IsZero() {
+		segment.StartTime = float64(start.UnixNano()) / float64(time.Second)
+	}
+}
+
+// X-Ray segments always end at the time they're closed, so explicit end times are applied
+// by the emitter of the root segment, right before the trace is sent.
+func setEndTime(segment *xray.Segment, end time.Time) {
+	root := segment.ParentSegment
+	if root == nil {
+		// The SDK is disabled and the segment is never sent.
+		return
+	}
+
+	// The root segment is locked whenever the trace is emitted.
+	root.Lock()
+	defer root.Unlock()
+
+	config := root.GetConfiguration()
+	emitter, ok := config.Emitter.(*endTimeEmitter)
+	if !ok {
+		emitter = &endTimeEmitter{Emitter: config.Emitter}
+		config.Emitter = emitter
+	}
+	emitter.setEndTime(segment, end)
+}
+
+// endTimeEmitter sets the explicit end times of the segments of a trace before emitting it.
+type endTimeEmitter struct {
+	xray.Emitter
+
+	mu       sync.Mutex
+	endTimes map[*xray.Segment]time.Time
+}
+
+func (e *endTimeEmitter) setEndTime(segment *xray.Segment, end time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.endTimes == nil {
+		e.endTimes = make(map[*xray.Segment]time.Time)
+	}
+	e.endTimes[segment] = end
+}
+
+// Emit sets the explicit end times and emits the segment. The emitted segment is already
+// locked by the caller, unlike its closed subsegments.
+func (e *endTimeEmitter) Emit(seg *xray.Segment) {
+	e.mu.Lock()
+	endTimes := e.endTimes
+	e.endTimes = nil
+	e.mu.Unlock()
+
+	for segment, end := range endTimes {
+		if segment != seg {
+			segment.Lock()
+		}
+		segment.EndTime = float64(end.UnixNano()) / float64(time.Second)
+		if segment != seg {
+			segment.Unlock()
+		}
+	}
+
+	e.Emitter.Emit(seg)
+}
+
+func addEventMetadata(segment *xray.Segment, name string, event map[string]any) {
+	segment.Lock()
+	defer segment.Unlock()
+
+	if segment.Metadata == nil {
+		segment.Metadata = make(map[string]map[string]any)
+	}
+	if segment.Metadata[eventsNamespace] == nil {
+		segment.Metadata[eventsNamespace] = make(map[string]any)
+	}
+
+	occurrences, _ := segment.Metadata[eventsNamespace][name].([]map[string]any)
+	segment.Metadata[eventsNamespace][name] = append(occurrences, event)
+}
+
 // As per the docs, the only values allowed for annotations are bool, int, uint,
 // float32, float64, and string. We don't want to require users to remember about
 // this so we'll convert the unsupported types to something that is supported:
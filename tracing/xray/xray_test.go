@@ -0,0 +1,85 @@
+package xray_test
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/strategy/sampling"
+	awsxray "github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/spacelift-io/spcontext"
+	"github.com/spacelift-io/spcontext/tracing/xray"
+)
+
+type capturingEmitter struct {
+	mu       sync.Mutex
+	segments []*awsxray.Segment
+}
+
+func (e *capturingEmitter) Emit(seg *awsxray.Segment) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.segments = append(e.segments, seg)
+}
+
+func (e *capturingEmitter) RefreshEmitterWithAddress(*net.UDPAddr) {}
+
+// newContext returns a context which samples all the segments and emits them to the emitter.
+func newContext(t *testing.T, emitter awsxray.Emitter) *spcontext.Context {
+	samplingStrategy, err := sampling.NewLocalizedStrategyFromJSONBytes([]byte(`{"version": 2, "default": {"fixed_target": 0, "rate": 1}}`))
+	require.NoError(t, err)
+
+	ctx := spcontext.New(log.NewNopLogger(), spcontext.WithTracer(&xray.Tracer{}))
+	return spcontext.WithValue(ctx, awsxray.RecorderContextKey{}, &awsxray.Config{
+		Emitter:          emitter,
+		SamplingStrategy: samplingStrategy,
+	})
+}
+
+func TestEndTime(t *testing.T) {
+	emitter := new(capturingEmitter)
+	ctx := newContext(t, emitter)
+
+	rootEnd := time.Now().Add(-time.Minute)
+	childEnd := rootEnd.Add(-time.Second)
+
+	ctx, root := ctx.StartSpan(spcontext.WithStartTime(rootEnd.Add(-time.Hour)))
+	childCtx, child := ctx.StartSpan(spcontext.WithStartTime(rootEnd.Add(-time.Hour)))
+	childSegment := awsxray.GetSegment(childCtx)
+
+	// The child is closed last, so the whole trace is emitted while closing it.
+	root.Close(nil, spcontext.WithEndTime(rootEnd))
+	child.Close(nil, spcontext.WithEndTime(childEnd))
+
+	require.Len(t, emitter.segments, 1)
+	assert.Equal(t, awsxray.GetSegment(ctx), emitter.segments[0])
+	assert.InDelta(t, toSeconds(rootEnd), emitter.segments[0].EndTime, 1e-6)
+	assert.InDelta(t, toSeconds(childEnd), childSegment.EndTime, 1e-6)
+}
+
+func toSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
+
+func TestPropagation(t *testing.T) {
+	emitter := new(capturingEmitter)
+	ctx := newContext(t, emitter)
+
+	producerCtx, producer := ctx.StartSpan(spcontext.WithOperation("producer"))
+	carrier := spcontext.MapCarrier{}
+	spcontext.Inject(producerCtx, carrier)
+	producer.Close(nil)
+
+	_, consumer := spcontext.Extract(ctx, carrier).StartSpan(spcontext.WithOperation("consumer"))
+	consumer.Close(nil)
+
+	require.Len(t, emitter.segments, 2)
+	assert.Equal(t, emitter.segments[0].TraceID, emitter.segments[1].TraceID)
+	assert.Equal(t, emitter.segments[0].ID, emitter.segments[1].ParentID)
+}
@@ -0,0 +1,227 @@
+package spcontext_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/spacelift-io/spcontext"
+)
+
+type spanEvent struct {
+	name      string
+	timestamp time.Time
+	fields    []interface{}
+}
+
+// recordingTracer records the spans and span events it receives.
+type recordingTracer struct {
+	spcontext.NopTracer
+	started []spcontext.SpanConfig
+	closed  []spcontext.SpanCloseConfig
+	errs    []error
+	fields  [][]interface{}
+	events  []spanEvent
+}
+
+func (r *recordingTracer) OnSpanCloseWithConfig(ctx *spcontext.Context, err error, fields []interface{}, cfg spcontext.SpanCloseConfig) {
+	r.closed = append(r.closed, cfg)
+	r.errs = append(r.errs, err)
+	r.fields = append(r.fields, fields)
+}
+
+func (r *recordingTracer) OnSpanStartWithConfig(ctx *spcontext.Context, cfg spcontext.SpanConfig) *spcontext.Context {
+	r.started = append(r.started, cfg)
+	return ctx
+}
+
+func (r *recordingTracer) GetSpanRef(ctx *spcontext.Context) spcontext.SpanRef {
+	return spcontext.SpanRef{"test.span_id": fmt.Sprint(len(r.started))}
+}
+
+func (r *recordingTracer) OnSpanEvent(ctx *spcontext.Context, name string, timestamp time.Time, fields []interface{}) {
+	r.events = append(r.events, spanEvent{name: name, timestamp: timestamp, fields: fields})
+}
+
+// fieldMap converts alternating keys and values into a map, with the latest values taking precedence.
+func fieldMap(kvs []interface{}) map[string]interface{} {
+	out := make(map[string]interface{})
+	for i := 0; i < len(kvs)/2; i++ {
+		out[kvs[2*i].(string)] = kvs[2*i+1]
+	}
+	return out
+}
+
+// basicTracer only implements the Tracer interface.
+type basicTracer struct {
+	spcontext.NopTracer
+	names, resources []string
+	drops, analyzes  []bool
+}
+
+func (b *basicTracer) OnSpanStart(ctx *spcontext.Context, name, resource string) *spcontext.Context {
+	b.names = append(b.names, name)
+	b.resources = append(b.resources, resource)
+	return ctx
+}
+
+func (b *basicTracer) OnSpanClose(ctx *spcontext.Context, err error, fields []interface{}, drop, analyze bool) {
+	b.drops = append(b.drops, drop)
+	b.analyzes = append(b.analyzes, analyze)
+}
+
+func TestBasicTracer(t *testing.T) {
+	tracer := new(basicTracer)
+	ctx := spcontext.New(log.NewNopLogger(), spcontext.WithTracer(tracer))
+
+	spanCtx, span := ctx.StartSpan(
+		spcontext.WithOperation("plan"),
+		spcontext.WithResource("stack-1"),
+		spcontext.WithSpanKind(spcontext.SpanKindConsumer),
+		spcontext.WithNewRoot(),
+	)
+	span.AddEvent("plan.started")
+	span.Drop()
+	span.Close(nil, spcontext.WithEndTime(time.Now()))
+
+	assert.Equal(t, []string{"plan"}, tracer.names)
+	assert.Equal(t, []string{"stack-1"}, tracer.resources)
+	assert.Equal(t, []bool{true}, tracer.drops)
+	assert.Equal(t, []bool{false}, tracer.analyzes)
+	assert.Nil(t, spanCtx.SpanRef())
+}
+
+func TestSpanEvents(t *testing.T) {
+	tracer := &recordingTracer{}
+	ctx := spcontext.New(log.NewNopLogger(), spcontext.WithTracer(tracer))
+
+	_, span := ctx.StartSpan()
+	before := time.Now()
+	span.AddEvent("plan started")
+	span.AddEvent("state uploaded", "size", 1024)
+	span.Close(nil)
+
+	if assert.Len(t, tracer.events, 2) {
+		assert.Equal(t, "plan started", tracer.events[0].name)
+		assert.Empty(t, tracer.events[0].fields)
+		assert.False(t, tracer.events[0].timestamp.Before(before))
+
+		assert.Equal(t, "state uploaded", tracer.events[1].name)
+		assert.Equal(t, []interface{}{"size", 1024}, tracer.events[1].fields)
+	}
+}
+
+func TestSpanLinks(t *testing.T) {
+	tracer := &recordingTracer{}
+	ctx := spcontext.New(log.NewNopLogger(), spcontext.WithTracer(tracer))
+
+	producerCtx, producerSpan := ctx.StartSpan(spcontext.WithOperation("enqueue"))
+	payload, err := json.Marshal(producerCtx.SpanRef())
+	assert.NoError(t, err)
+	producerSpan.Close(nil)
+
+	var ref spcontext.SpanRef
+	assert.NoError(t, json.Unmarshal(payload, &ref))
+
+	_, consumerSpan := ctx.StartSpan(spcontext.WithOperation("consume"), spcontext.WithNewRoot(), spcontext.WithLinks(ref))
+	consumerSpan.Close(nil)
+
+	if assert.Len(t, tracer.started, 2) {
+		assert.False(t, tracer.started[0].NewRoot)
+		assert.Empty(t, tracer.started[0].Links)
+
+		assert.True(t, tracer.started[1].NewRoot)
+		assert.Equal(t, []spcontext.SpanRef{{"test.span_id": "1"}}, tracer.started[1].Links)
+	}
+}
+
+func TestSpanKindAndTimestamps(t *testing.T) {
+	tracer := &recordingTracer{}
+	ctx := spcontext.New(log.NewNopLogger(), spcontext.WithTracer(tracer))
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	end := start.Add(42 * time.Second)
+
+	_, span := ctx.StartSpan(spcontext.WithSpanKind(spcontext.SpanKindConsumer), spcontext.WithStartTime(start))
+	span.Drop()
+	span.Close(nil, spcontext.WithEndTime(end))
+
+	if assert.Len(t, tracer.started, 1) && assert.Len(t, tracer.closed, 1) {
+		assert.Equal(t, spcontext.SpanKindConsumer, tracer.started[0].Kind)
+		assert.Equal(t, start, tracer.started[0].StartTime)
+		assert.Equal(t, spcontext.SpanCloseConfig{Drop: true, EndTime: end}, tracer.closed[0])
+	}
+}
+
+func TestTrace(t *testing.T) {
+	setup := func() (*spcontext.Context, *recordingTracer) {
+		tracer := &recordingTracer{}
+		return spcontext.New(log.NewNopLogger(), spcontext.WithTracer(tracer)), tracer
+	}
+
+	t.Run("closes the span with the returned error", func(t *testing.T) {
+		ctx, tracer := setup()
+		failure := errors.New("plan failed")
+
+		err := spcontext.Trace(ctx, []spcontext.SpanOption{spcontext.WithResource("stack-1")}, func(ctx *spcontext.Context) error {
+			return failure
+		})
+
+		assert.Equal(t, failure, err)
+		if assert.Len(t, tracer.started, 1) && assert.Len(t, tracer.errs, 1) {
+			assert.Contains(t, tracer.started[0].Operation, "TestTrace")
+			assert.Equal(t, "stack-1", tracer.started[0].Resource)
+			assert.Equal(t, failure, tracer.errs[0])
+		}
+	})
+
+	t.Run("returns the value", func(t *testing.T) {
+		ctx, tracer := setup()
+
+		value, err := spcontext.TraceValue(ctx, nil, func(ctx *spcontext.Context) (int, error) {
+			return 42, nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 42, value)
+		if assert.Len(t, tracer.errs, 1) {
+			assert.NoError(t, tracer.errs[0])
+		}
+	})
+
+	t.Run("closes the span with the panic", func(t *testing.T) {
+		ctx, tracer := setup()
+
+		assert.PanicsWithValue(t, "state is corrupted", func() {
+			_, _ = spcontext.TraceValue(ctx, nil, func(ctx *spcontext.Context) (string, error) {
+				panic("state is corrupted")
+			})
+		})
+
+		if assert.Len(t, tracer.errs, 1) {
+			assert.EqualError(t, tracer.errs[0], "panic: state is corrupted")
+		}
+	})
+
+	t.Run("closes the span with the named error", func(t *testing.T) {
+		ctx, tracer := setup()
+		failure := errors.New("apply failed")
+
+		apply := func() (err error) {
+			_, span := ctx.StartSpan()
+			defer span.CloseWith(&err)
+
+			return failure
+		}
+
+		assert.Equal(t, failure, apply())
+		if assert.Len(t, tracer.errs, 1) {
+			assert.Equal(t, failure, tracer.errs[0])
+		}
+	})
+}